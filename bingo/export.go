@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/raff/ebi-games/bingo/game"
+)
+
+const exportSize = 2000 // screen size for the card layout, when exporting (cards are half of it)
+
+// Export generates n cards (for the current rules) with unique IDs (from a random generator with the given seed) and writes them
+// to out: a PDF document with one card per page if out ends with .pdf, otherwise a directory with one PNG file per card.
+func Export(n int, out string, seed int64) error {
+	loadFont()
+	setLayout(exportSize, exportSize)
+
+	rng := rand.New(rand.NewSource(seed))
+	seen := map[int64]bool{}
+
+	var cards []*image.RGBA
+	var ids []string
+
+	for len(cards) < n {
+		s := rng.Int63n(game.MaxSeed)
+		if seen[s] {
+			continue
+		}
+
+		seen[s] = true
+
+		card := game.NewCardFromSeed(rules, s)
+		img := renderCard(card, nil)
+
+		// without the caller the panel only has the card ID
+		h := (letterRows()+rules.H)*th + border + 2*small.Metrics().Height.Ceil()
+		cards = append(cards, img.SubImage(image.Rect(0, 0, ww, h)).(*image.RGBA))
+		ids = append(ids, card.ID)
+	}
+
+	if strings.EqualFold(filepath.Ext(out), ".pdf") {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+
+		if err := WritePDF(f, cards); err != nil {
+			f.Close()
+			return err
+		}
+
+		return f.Close()
+	}
+
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return err
+	}
+
+	for i, img := range cards {
+		if err := writePNG(filepath.Join(out, "card-"+ids[i]+".png"), img); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// WritePDF writes a PDF document with one image per page, scaled to fit an A4 page
+func WritePDF(w io.Writer, images []*image.RGBA) error {
+	const (
+		pageW, pageH = 595, 842 // A4, in points
+		margin       = 36
+	)
+
+	bw := bufio.NewWriter(w)
+
+	var offsets []int // object offsets, for the cross-reference table
+	pos := 0
+
+	write := func(format string, args ...interface{}) {
+		n, _ := fmt.Fprintf(bw, format, args...)
+		pos += n
+	}
+
+	object := func(body string, stream []byte) {
+		offsets = append(offsets, pos)
+		write("%d 0 obj\n%s\n", len(offsets), body)
+
+		if stream != nil {
+			write("stream\n")
+			n, _ := bw.Write(stream)
+			pos += n
+			write("\nendstream\n")
+		}
+
+		write("endobj\n")
+	}
+
+	// objects: 1 catalog, 2 pages, then page, contents and image for each page
+	var kids []string
+	for i := range images {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 3+i*3))
+	}
+
+	write("%%PDF-1.4\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>", nil)
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(images)), nil)
+
+	for i, img := range images {
+		page := 3 + i*3
+		iw, ih := img.Bounds().Dx(), img.Bounds().Dy()
+
+		scale := float64(pageW-2*margin) / float64(iw)
+		if s := float64(pageH-2*margin) / float64(ih); s < scale {
+			scale = s
+		}
+
+		dw, dh := float64(iw)*scale, float64(ih)*scale
+		dx, dy := (pageW-dw)/2, pageH-margin-dh // top of the page
+
+		contents := fmt.Sprintf("q %.2f 0 0 %.2f %.2f %.2f cm /Im0 Do Q", dw, dh, dx, dy)
+
+		var data bytes.Buffer
+
+		zw := zlib.NewWriter(&data)
+		for y := 0; y < ih; y++ {
+			for x := 0; x < iw; x++ {
+				c := img.RGBAAt(x, y)
+				zw.Write([]byte{c.R, c.G, c.B})
+			}
+		}
+
+		if err := zw.Close(); err != nil {
+			return err
+		}
+
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Contents %d 0 R /Resources << /XObject << /Im0 %d 0 R >> >> >>",
+			pageW, pageH, page+1, page+2), nil)
+		object(fmt.Sprintf("<< /Length %d >>", len(contents)), []byte(contents))
+		object(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode /Length %d >>",
+			iw, ih, data.Len()), data.Bytes())
+	}
+
+	xref := pos
+
+	write("xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		write("%010d 00000 n \n", off)
+	}
+
+	write("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return bw.Flush()
+}
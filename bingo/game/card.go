@@ -0,0 +1,220 @@
+// Package game is the bingo game logic: cards, rules, winning patterns and the network protocol.
+// It doesn't depend on ebiten.
+package game
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+const (
+	Free  = 0  // the free center cell
+	Blank = -1 // a blank cell, without a number
+
+	MaxSeed = 1 << 40 // card seeds are 0..MaxSeed-1 (up to 8 characters IDs)
+)
+
+// A Card is a player card: a grid of numbers, with free and blank cells as defined by the rules
+type Card struct {
+	ID      string // the card seed, see NewCardFromID
+	W, H    int
+	Numbers []int  // by row, Free for the free cell and Blank for blank cells
+	Marked  []bool // cells marked by the player (free and blank cells are always marked)
+	Rules   *Rules
+}
+
+// NewCard returns a new random card for the rules r
+func NewCard(r *Rules, rng *rand.Rand) *Card {
+	c := &Card{
+		W:       r.W,
+		H:       r.H,
+		Numbers: make([]int, r.W*r.H),
+		Marked:  make([]bool, r.W*r.H),
+		Rules:   r,
+	}
+
+	r.fill(c, rng)
+
+	for i := range c.Numbers {
+		c.Marked[i] = c.Fixed(i)
+	}
+
+	return c
+}
+
+// CardID returns the ID for the card generated from seed
+func CardID(seed int64) string {
+	return strings.ToUpper(strconv.FormatInt(seed, 36))
+}
+
+// NewCardFromSeed returns the card generated from seed, with its ID
+func NewCardFromSeed(r *Rules, seed int64) *Card {
+	c := NewCard(r, rand.New(rand.NewSource(seed)))
+	c.ID = CardID(seed)
+	return c
+}
+
+// NewCardFromID returns the card with the given ID (the same card NewCardFromSeed returned, for the same rules)
+func NewCardFromID(r *Rules, id string) (*Card, error) {
+	seed, err := strconv.ParseInt(strings.ToLower(id), 36, 64)
+	if err != nil || seed < 0 || seed >= MaxSeed {
+		return nil, fmt.Errorf("invalid card ID %q", id)
+	}
+
+	return NewCardFromSeed(r, seed), nil
+}
+
+// Get returns the number at x,y
+func (c *Card) Get(x, y int) int {
+	return c.Numbers[y*c.W+x]
+}
+
+// IsMarked returns true if the cell at x,y is marked
+func (c *Card) IsMarked(x, y int) bool {
+	return c.Marked[y*c.W+x]
+}
+
+// Fixed returns true if the cell i is always marked (a free or blank cell)
+func (c *Card) Fixed(i int) bool {
+	return c.Numbers[i] == Free || c.Numbers[i] == Blank
+}
+
+// Mark marks (or unmarks) the cell at x,y, if its number has been called.
+// It returns false if the cell can't be marked.
+func (c *Card) Mark(x, y int, caller *Caller) bool {
+	i := y*c.W + x
+
+	if c.Fixed(i) || !caller.Called(c.Numbers[i]) {
+		return false
+	}
+
+	c.Marked[i] = !c.Marked[i]
+	return true
+}
+
+// A Pattern is a named set of cells (by index) that wins when all are marked
+type Pattern struct {
+	Name  string
+	Cells []int
+}
+
+// Patterns returns the winning patterns for a w x h card:
+// rows, columns, diagonals (for square cards) and the four corners
+func Patterns(w, h int) []Pattern {
+	var list []Pattern
+
+	for y := 0; y < h; y++ {
+		p := Pattern{Name: "row"}
+		for x := 0; x < w; x++ {
+			p.Cells = append(p.Cells, y*w+x)
+		}
+
+		list = append(list, p)
+	}
+
+	for x := 0; x < w; x++ {
+		p := Pattern{Name: "column"}
+		for y := 0; y < h; y++ {
+			p.Cells = append(p.Cells, y*w+x)
+		}
+
+		list = append(list, p)
+	}
+
+	if w == h {
+		d1, d2 := Pattern{Name: "diagonal"}, Pattern{Name: "diagonal"}
+
+		for i := 0; i < w; i++ {
+			d1.Cells = append(d1.Cells, i*w+i)
+			d2.Cells = append(d2.Cells, i*w+(w-1-i))
+		}
+
+		list = append(list, d1, d2)
+	}
+
+	list = append(list, Pattern{Name: "four corners", Cells: []int{0, w - 1, (h - 1) * w, h*w - 1}})
+	return list
+}
+
+// Bingo returns the winning patterns completed on the card
+func (c *Card) Bingo() []Pattern {
+	var won []Pattern
+
+patterns:
+	for _, p := range c.Rules.Patterns {
+		for _, i := range p.Cells {
+			if !c.Marked[i] {
+				continue patterns
+			}
+		}
+
+		won = append(won, p)
+	}
+
+	return won
+}
+
+// A Caller draws the numbers, without repeats
+type Caller struct {
+	pool    []int // numbers not drawn yet
+	History []int // numbers drawn, in order
+	called  map[int]bool
+}
+
+// NewCaller returns a caller for the numbers 1..max
+func NewCaller(max int, rng *rand.Rand) *Caller {
+	c := &Caller{called: map[int]bool{}}
+
+	for _, n := range rng.Perm(max) {
+		c.pool = append(c.pool, n+1)
+	}
+
+	return c
+}
+
+// Draw returns the next number. It returns false if all the numbers have been drawn.
+func (c *Caller) Draw() (int, bool) {
+	if len(c.pool) == 0 {
+		return 0, false
+	}
+
+	n := c.pool[0]
+	c.pool = c.pool[1:]
+
+	c.History = append(c.History, n)
+	c.called[n] = true
+	return n, true
+}
+
+// Add records the number n, drawn by someone else (the host)
+func (c *Caller) Add(n int) {
+	if c.called[n] {
+		return
+	}
+
+	for i, p := range c.pool {
+		if p == n {
+			c.pool = append(c.pool[:i], c.pool[i+1:]...)
+			break
+		}
+	}
+
+	c.History = append(c.History, n)
+	c.called[n] = true
+}
+
+// Called returns true if the number n has been drawn
+func (c *Caller) Called(n int) bool {
+	return c.called[n]
+}
+
+// Last returns the last number drawn, or 0 if none
+func (c *Caller) Last() int {
+	if len(c.History) == 0 {
+		return 0
+	}
+
+	return c.History[len(c.History)-1]
+}
@@ -0,0 +1,348 @@
+package game
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	queueSize    = 256             // messages waiting to be sent to a player (more than the numbers in a game)
+	writeTimeout = 5 * time.Second // a player that doesn't read for this long is disconnected
+)
+
+/*
+ * Network protocol: one JSON message per line.
+ *
+ *   client -> host  {"type":"join","name":"...","card":"ID","rules":"75-ball"}
+ *   client -> host  {"type":"claim","marked":[cell indices]}
+ *   host -> client  {"type":"number","number":N}    (on join, all the numbers called so far)
+ *   host -> client  {"type":"result","name":"...","valid":true|false,"patterns":[...]}
+ *   host -> client  {"type":"error","text":"..."}
+ */
+
+// A Message is a message between host and clients
+type Message struct {
+	Type     string   `json:"type"`
+	Name     string   `json:"name,omitempty"`
+	Card     string   `json:"card,omitempty"`
+	Rules    string   `json:"rules,omitempty"`
+	Number   int      `json:"number,omitempty"`
+	Marked   []int    `json:"marked,omitempty"`
+	Valid    bool     `json:"valid,omitempty"`
+	Patterns []string `json:"patterns,omitempty"`
+	Text     string   `json:"text,omitempty"`
+}
+
+// Describe returns a description of the message, for the players of a game with the rules r
+func (m Message) Describe(r *Rules) string {
+	switch m.Type {
+	case "number":
+		return "called " + r.Label(m.Number)
+
+	case "result":
+		if m.Valid {
+			return fmt.Sprintf("BINGO! by %v (%v)", m.Name, strings.Join(m.Patterns, ", "))
+		}
+
+		return fmt.Sprintf("false BINGO by %v", m.Name)
+
+	case "join":
+		return m.Name + " joined"
+
+	case "error":
+		return "error: " + m.Text
+	}
+
+	return m.Type
+}
+
+type player struct {
+	conn net.Conn
+	out  chan Message // messages to send, closed when the player leaves
+	name string
+	card *Card // the host copy of the player card
+}
+
+// queue adds a message for the player, without blocking: a player that can't keep up is disconnected.
+// Call it with the host lock held.
+func (p *player) queue(m Message) {
+	select {
+	case p.out <- m:
+	default:
+		p.conn.Close()
+	}
+}
+
+// write sends the queued messages to the player, and closes the connection when the queue is closed
+func (p *player) write() {
+	defer p.conn.Close()
+
+	enc := json.NewEncoder(p.conn)
+
+	for m := range p.out {
+		p.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+
+		if err := enc.Encode(m); err != nil {
+			p.conn.Close() // stops handle, that closes the queue
+
+			for range p.out {
+			}
+
+			return
+		}
+	}
+}
+
+// A Host calls the numbers for all the players and checks their claims
+type Host struct {
+	ln    net.Listener
+	rules *Rules // the players must use the same rules
+
+	mu      sync.Mutex
+	players map[*player]bool
+	history []int
+	called  map[int]bool
+
+	Events chan Message // join and result events, for the host UI
+}
+
+// NewHost starts listening for players at addr (host:port), for a game with the rules r
+func NewHost(addr string, r *Rules) (*Host, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Host{
+		ln:      ln,
+		rules:   r,
+		players: map[*player]bool{},
+		called:  map[int]bool{},
+		Events:  make(chan Message, 64),
+	}
+
+	go h.serve()
+	return h, nil
+}
+
+// Addr returns the address the host is listening to
+func (h *Host) Addr() string {
+	return h.ln.Addr().String()
+}
+
+// Close stops the host and disconnects all the players
+func (h *Host) Close() error {
+	err := h.ln.Close()
+
+	h.mu.Lock()
+	for p := range h.players {
+		p.conn.Close()
+	}
+	h.mu.Unlock()
+
+	return err
+}
+
+func (h *Host) serve() {
+	for {
+		conn, err := h.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		go h.handle(conn)
+	}
+}
+
+// event sends an event to the host UI (dropping it if nobody is listening)
+func (h *Host) event(m Message) {
+	select {
+	case h.Events <- m:
+	default:
+	}
+}
+
+// handle reads the messages from a player
+func (h *Host) handle(conn net.Conn) {
+	p := &player{conn: conn, out: make(chan Message, queueSize)}
+	go p.write()
+
+	defer func() {
+		h.mu.Lock()
+		delete(h.players, p)
+		close(p.out) // write sends what's left (the error messages) and closes the connection
+		h.mu.Unlock()
+	}()
+
+	dec := json.NewDecoder(bufio.NewReader(conn))
+
+	for {
+		var m Message
+		if err := dec.Decode(&m); err != nil {
+			return
+		}
+
+		switch m.Type {
+		case "join":
+			if m.Rules != h.rules.Name {
+				h.send(p, Message{Type: "error", Text: "the game uses the " + h.rules.Name + " rules"})
+				return
+			}
+
+			card, err := NewCardFromID(h.rules, m.Card)
+			if err != nil {
+				h.send(p, Message{Type: "error", Text: err.Error()})
+				return
+			}
+
+			h.mu.Lock()
+			p.name, p.card = m.Name, card
+			h.players[p] = true
+
+			for _, n := range h.history { // catch up
+				p.queue(Message{Type: "number", Number: n})
+			}
+			h.mu.Unlock()
+
+			h.event(m)
+
+		case "claim":
+			if p.card == nil {
+				h.send(p, Message{Type: "error", Text: "not joined"})
+				continue
+			}
+
+			res := h.check(p, m.Marked)
+
+			h.broadcast(res)
+			h.event(res)
+		}
+	}
+}
+
+// check verifies a player claim: all the marked cells must have been called,
+// and they must complete a winning pattern on the host copy of the player card
+func (h *Host) check(p *player, marked []int) Message {
+	res := Message{Type: "result", Name: p.name}
+
+	card := *p.card
+	card.Marked = make([]bool, len(card.Numbers))
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i := range card.Numbers {
+		card.Marked[i] = card.Fixed(i)
+	}
+
+	for _, i := range marked {
+		if i < 0 || i >= len(card.Numbers) {
+			return res
+		}
+
+		if !card.Fixed(i) && !h.called[card.Numbers[i]] {
+			return res
+		}
+
+		card.Marked[i] = true
+	}
+
+	for _, w := range card.Bingo() {
+		res.Patterns = append(res.Patterns, w.Name)
+	}
+
+	res.Valid = len(res.Patterns) > 0
+	return res
+}
+
+// send sends a message to a player
+func (h *Host) send(p *player, m Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	p.queue(m)
+}
+
+// broadcast sends a message to all the players
+func (h *Host) broadcast(m Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for p := range h.players {
+		p.queue(m)
+	}
+}
+
+// Call sends the number n to all the players
+func (h *Host) Call(n int) {
+	h.mu.Lock()
+	h.history = append(h.history, n)
+	h.called[n] = true
+	h.mu.Unlock()
+
+	h.broadcast(Message{Type: "number", Number: n})
+}
+
+// A Client is a player connected to a host
+type Client struct {
+	conn net.Conn
+	enc  *json.Encoder
+
+	Messages chan Message // messages from the host, closed when disconnected
+}
+
+// Join connects to the host at addr, to play with the card
+func Join(addr, name string, card *Card) (*Client, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{conn: conn, enc: json.NewEncoder(conn), Messages: make(chan Message, 128)}
+
+	if err := c.enc.Encode(Message{Type: "join", Name: name, Card: card.ID, Rules: card.Rules.Name}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	go c.read()
+	return c, nil
+}
+
+func (c *Client) read() {
+	defer close(c.Messages)
+
+	dec := json.NewDecoder(bufio.NewReader(c.conn))
+
+	for {
+		var m Message
+		if err := dec.Decode(&m); err != nil {
+			return
+		}
+
+		c.Messages <- m
+	}
+}
+
+// Claim tells the host the card has a BINGO, sending the marked cells
+func (c *Client) Claim(card *Card) error {
+	var marked []int
+
+	for i, m := range card.Marked {
+		if m && !card.Fixed(i) {
+			marked = append(marked, i)
+		}
+	}
+
+	return c.enc.Encode(Message{Type: "claim", Marked: marked})
+}
+
+// Close disconnects from the host
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,229 @@
+package game
+
+import (
+	"encoding/json"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newHost(t *testing.T) *Host {
+	t.Helper()
+
+	h, err := NewHost("127.0.0.1:0", Variants["75"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func join(t *testing.T, h *Host, name string, card *Card) *Client {
+	t.Helper()
+
+	c, err := Join(h.Addr(), name, card)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// receive returns the next message of type typ from the host, skipping the others
+func receive(t *testing.T, c *Client, typ string) Message {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+
+	for {
+		select {
+		case m, ok := <-c.Messages:
+			if !ok {
+				t.Fatalf("disconnected waiting for %q", typ)
+			}
+
+			if m.Type == typ {
+				return m
+			}
+
+		case <-timeout:
+			t.Fatalf("timeout waiting for %q", typ)
+		}
+	}
+}
+
+// joined waits for the host to accept a player
+func joined(t *testing.T, h *Host, name string) {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+
+	for {
+		select {
+		case m := <-h.Events:
+			if m.Type == "join" && m.Name == name {
+				return
+			}
+
+		case <-timeout:
+			t.Fatalf("timeout waiting for %v to join", name)
+		}
+	}
+}
+
+// disconnected checks that the host closes the connection
+func disconnected(t *testing.T, c *Client) {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+
+	for {
+		select {
+		case _, ok := <-c.Messages:
+			if !ok {
+				return
+			}
+
+		case <-timeout:
+			t.Fatal("still connected")
+		}
+	}
+}
+
+func TestCatchUp(t *testing.T) {
+	h := newHost(t)
+	h.Call(5)
+	h.Call(20)
+
+	alice := join(t, h, "alice", NewCardFromSeed(Variants["75"], 1))
+	joined(t, h, "alice")
+
+	h.Call(33)
+
+	bob := join(t, h, "bob", NewCardFromSeed(Variants["75"], 2))
+
+	for _, c := range []*Client{alice, bob} {
+		for _, n := range []int{5, 20, 33} {
+			if m := receive(t, c, "number"); m.Number != n {
+				t.Fatalf("got number %d, want %d", m.Number, n)
+			}
+		}
+	}
+}
+
+func TestClaim(t *testing.T) {
+	h := newHost(t)
+
+	card := NewCardFromSeed(Variants["75"], 1)
+	other := NewCardFromSeed(Variants["75"], 2)
+
+	alice := join(t, h, "alice", card)
+	joined(t, h, "alice")
+	bob := join(t, h, "bob", other)
+	joined(t, h, "bob")
+
+	// call and mark the first row
+	called := map[int]bool{}
+
+	for x := 0; x < card.W; x++ {
+		n := card.Get(x, 0)
+		h.Call(n)
+		called[n] = true
+		card.Marked[x] = true
+	}
+
+	if err := alice.Claim(card); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []*Client{alice, bob} {
+		m := receive(t, c, "result")
+		if !m.Valid || m.Name != "alice" || !reflect.DeepEqual(m.Patterns, []string{"row"}) {
+			t.Errorf("got %+v, want a valid row for alice", m)
+		}
+	}
+
+	// mark the first row of the other card, with numbers that were not called
+	uncalled := false
+
+	for x := 0; x < other.W; x++ {
+		other.Marked[x] = true
+		uncalled = uncalled || !called[other.Get(x, 0)]
+	}
+
+	if !uncalled {
+		t.Fatal("all the numbers were called")
+	}
+
+	if err := bob.Claim(other); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []*Client{alice, bob} {
+		if m := receive(t, c, "result"); m.Valid || m.Name != "bob" {
+			t.Errorf("got %+v, want a false claim by bob", m)
+		}
+	}
+}
+
+func TestJoinErrors(t *testing.T) {
+	h := newHost(t)
+
+	tests := []struct {
+		name string
+		card *Card
+		want string
+	}{
+		{"rules mismatch", NewCardFromSeed(Variants["90"], 1), "the game uses the 75-ball rules"},
+		{"bad card ID", &Card{ID: "not-an-id", Rules: Variants["75"]}, `invalid card ID "not-an-id"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := join(t, h, "mallory", tt.card)
+
+			if m := receive(t, c, "error"); m.Text != tt.want {
+				t.Errorf("got error %q, want %q", m.Text, tt.want)
+			}
+
+			disconnected(t, c)
+		})
+	}
+}
+
+// TestStalledPlayer checks that a player that doesn't read doesn't block the host
+func TestStalledPlayer(t *testing.T) {
+	h := newHost(t)
+
+	conn, err := net.Dial("tcp", h.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	json.NewEncoder(conn).Encode(Message{Type: "join", Name: "stalled", Card: "1", Rules: "75-ball"})
+	joined(t, h, "stalled")
+
+	done := make(chan bool)
+	go func() {
+		// more than the connection buffers can hold
+		text := strings.Repeat("x", 1000)
+
+		for i := 0; i < 10000; i++ {
+			h.broadcast(Message{Type: "error", Text: text})
+		}
+
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the host is blocked")
+	}
+}
@@ -0,0 +1,285 @@
+package game
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Rules define a bingo variant: the card grid, the numbers in each column and the winning patterns
+type Rules struct {
+	Name     string
+	W, H     int
+	Max      int    // numbers are 1..Max
+	Starts   []int  // first number of each column (column x has numbers from Starts[x] to Starts[x+1]-1 or Max)
+	Letters  string // column letters, shown above the card ("" for none)
+	Free     bool   // the center cell is free
+	Blanks   int    // blank cells in each row
+	Patterns []Pattern
+}
+
+// Variants are the built-in rule sets, by name (see the -rules flag)
+var Variants = map[string]*Rules{
+	"75": {
+		Name:     "75-ball",
+		W:        5,
+		H:        5,
+		Max:      75,
+		Starts:   []int{1, 16, 31, 46, 61},
+		Letters:  "BINGO",
+		Free:     true,
+		Patterns: Patterns(5, 5),
+	},
+
+	"90": {
+		Name:     "90-ball",
+		W:        9,
+		H:        3,
+		Max:      90,
+		Starts:   []int{1, 10, 20, 30, 40, 50, 60, 70, 80},
+		Blanks:   4,
+		Patterns: Lines(9, 3),
+	},
+
+	"30": {
+		Name:     "30-ball",
+		W:        3,
+		H:        3,
+		Max:      30,
+		Starts:   []int{1, 11, 21},
+		Patterns: []Pattern{FullHouse(3, 3)},
+	},
+}
+
+// Lines returns the winning patterns for a ticket with h rows of w cells:
+// one line, two lines (for tickets with 3 rows) and the full house
+func Lines(w, h int) []Pattern {
+	var list []Pattern
+
+	for y := 0; y < h; y++ {
+		p := Pattern{Name: "one line"}
+		for x := 0; x < w; x++ {
+			p.Cells = append(p.Cells, y*w+x)
+		}
+
+		list = append(list, p)
+	}
+
+	if h == 3 {
+		for skip := h - 1; skip >= 0; skip-- { // two lines: all but one
+			p := Pattern{Name: "two lines"}
+			for i := 0; i < w*h; i++ {
+				if i/w != skip {
+					p.Cells = append(p.Cells, i)
+				}
+			}
+
+			list = append(list, p)
+		}
+	}
+
+	return append(list, FullHouse(w, h))
+}
+
+// FullHouse returns the pattern with all the cells of a w x h card
+func FullHouse(w, h int) Pattern {
+	p := Pattern{Name: "full house"}
+	for i := 0; i < w*h; i++ {
+		p.Cells = append(p.Cells, i)
+	}
+
+	return p
+}
+
+// Column returns the card column for the number n
+func (r *Rules) Column(n int) int {
+	x := 0
+	for x+1 < len(r.Starts) && n >= r.Starts[x+1] {
+		x++
+	}
+
+	return x
+}
+
+// Range returns the first and last number for column x
+func (r *Rules) Range(x int) (int, int) {
+	if x+1 < len(r.Starts) {
+		return r.Starts[x], r.Starts[x+1] - 1
+	}
+
+	return r.Starts[x], r.Max
+}
+
+// Label returns the number with its column letter, if the variant has letters (e.g. B12)
+func (r *Rules) Label(n int) string {
+	if r.Letters == "" {
+		return strconv.Itoa(n)
+	}
+
+	return string(r.Letters[r.Column(n)]) + strconv.Itoa(n)
+}
+
+// fill fills the card numbers, with the free and blank cells
+func (r *Rules) fill(c *Card, rng *rand.Rand) {
+	if r.Blanks > 0 {
+		r.fillTicket(c, rng)
+		return
+	}
+
+	for x := 0; x < c.W; x++ {
+		lo, hi := r.Range(x)
+
+		for y, n := range rng.Perm(hi - lo + 1)[:c.H] {
+			c.Numbers[y*c.W+x] = n + lo
+		}
+	}
+
+	if r.Free {
+		c.Numbers[(c.H/2)*c.W+c.W/2] = Free
+	}
+}
+
+// fillTicket fills a ticket with blanks (a UK 90-ball ticket): each row has the same number of blanks,
+// each column at least one number and the numbers in a column are sorted from top to bottom.
+func (r *Rules) fillTicket(c *Card, rng *rand.Rand) {
+	for i := range c.Numbers {
+		c.Numbers[i] = Blank
+	}
+
+	counts := make([]int, c.W)
+
+	for {
+		for x := range counts {
+			counts[x] = 0
+		}
+
+		for y := 0; y < c.H; y++ {
+			for _, x := range rng.Perm(c.W)[:c.W-r.Blanks] {
+				c.Numbers[y*c.W+x] = Free // placeholder, for the cells with a number
+				counts[x]++
+			}
+		}
+
+		ok := true
+		for _, n := range counts {
+			if n == 0 {
+				ok = false
+			}
+		}
+
+		if ok {
+			break
+		}
+
+		for i := range c.Numbers {
+			c.Numbers[i] = Blank
+		}
+	}
+
+	for x := 0; x < c.W; x++ {
+		lo, hi := r.Range(x)
+
+		nums := rng.Perm(hi - lo + 1)[:counts[x]]
+		sort.Ints(nums)
+
+		for y := 0; y < c.H; y++ {
+			if i := y*c.W + x; c.Numbers[i] == Free {
+				c.Numbers[i] = nums[0] + lo
+				nums = nums[1:]
+			}
+		}
+	}
+}
+
+// ReadPatterns reads the winning patterns for a w x h card from a text file.
+// Each pattern is a name followed by h lines of w characters (X for the cells in the pattern, . for the others),
+// patterns are separated by empty lines and lines starting with # are comments:
+//
+//	letter T
+//	XXXXX
+//	..X..
+//	..X..
+//	..X..
+//	..X..
+func ReadPatterns(path string, w, h int) ([]Pattern, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	var list []Pattern
+	var p *Pattern
+	var y int
+
+	end := func(line int) error {
+		if p == nil {
+			return nil
+		}
+
+		if y != h || len(p.Cells) == 0 {
+			return fmt.Errorf("%v:%d: pattern %q should have %d lines with some X", path, line, p.Name, h)
+		}
+
+		list = append(list, *p)
+		p = nil
+		return nil
+	}
+
+	scanner := bufio.NewScanner(f)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+		l := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case strings.HasPrefix(l, "#"):
+			continue
+
+		case l == "":
+			if err := end(line); err != nil {
+				return nil, err
+			}
+
+		case p == nil:
+			p = &Pattern{Name: l}
+			y = 0
+
+		case y >= h || len(l) != w:
+			return nil, fmt.Errorf("%v:%d: pattern %q should be %dx%d", path, line, p.Name, w, h)
+
+		default:
+			for x, c := range l {
+				switch c {
+				case 'X', 'x':
+					p.Cells = append(p.Cells, y*w+x)
+				case '.':
+				default:
+					return nil, fmt.Errorf("%v:%d: invalid character %q", path, line, c)
+				}
+			}
+
+			y++
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := end(line); err != nil {
+		return nil, err
+	}
+
+	if len(list) == 0 {
+		return nil, fmt.Errorf("%v: no patterns", path)
+	}
+
+	return list, nil
+}
@@ -1,31 +1,34 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"image/color"
 	"log"
 	"math/rand"
+	"strings"
 	"time"
 
 	"golang.org/x/image/font"
-	"golang.org/x/image/font/gofont/gobold"
-	"golang.org/x/image/font/opentype"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
-	"github.com/hajimehoshi/ebiten/v2/text"
+
+	"github.com/raff/ebi-games/bingo/game"
 )
 
 const (
-	hcount = 5
-	vcount = 5
 	border = 8
+
+	autoDelay = 3 * time.Second // time between numbers, with the automatic caller
 )
 
 var (
 	background  = color.NRGBA{80, 80, 80, 255}
 	borderColor = color.NRGBA{160, 160, 160, 255}
 	textColor   = color.NRGBA{0, 0, 0, 255}
+	markColor   = color.NRGBA{0, 0, 0, 128}
+	bingoColor  = color.NRGBA{255, 255, 0, 255}
 
 	colors = []color.NRGBA{
 		{255, 127, 0, 255},
@@ -42,6 +45,12 @@ var (
 
 	tw, th int // game tile width, height
 	ww, wh int // window width and height
+
+	rng    *rand.Rand
+	rules  = game.Variants["75"]
+	card   *game.Card
+	caller *game.Caller
+	cardID string // card to play (see NewCardFromID)
 )
 
 func min(a, b int) int {
@@ -52,97 +61,148 @@ func min(a, b int) int {
 	return b
 }
 
+// initCanvas computes the layout (for a w x h screen) and creates the canvas.
+// With w, h = 0 it starts a new game, with a new card.
 func initCanvas(w, h int) (int, int) {
 	if w > 0 && h > 0 {
-		ww, wh = w/2, h/2
-
-		ww = min(ww, wh)
-		wh = ww
-
-		tw = (ww - border) / hcount
-		th = (wh - border) / vcount
-
-		wh += th
+		setLayout(w, h)
 
 		canvas = ebiten.NewImage(ww, wh)
-		canvas.Fill(background)
+		loadFont()
+	}
 
-		tt, err := opentype.Parse(gobold.TTF)
+	if cardID != "" { // first game with the requested card
+		c, err := game.NewCardFromID(rules, cardID)
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		const dpi = 72
-		ffont, err = opentype.NewFace(tt, &opentype.FaceOptions{
-			Size:    48,
-			DPI:     dpi,
-			Hinting: font.HintingFull,
-		})
-		if err != nil {
-			log.Fatal(err)
-		}
+		card = c
+		cardID = ""
+	} else {
+		card = game.NewCardFromSeed(rules, rng.Int63n(game.MaxSeed))
 	}
 
-	tile := ebiten.NewImage(tw-border, th-border)
-	tile.Fill(borderColor)
+	caller = game.NewCaller(rules.Max, rng)
 
-	inset := tile.SubImage(tile.Bounds().Inset(border)).(*ebiten.Image)
+	drawCard()
+	return ww, wh
+}
 
-	var numbers []int
+// drawCard draws the card, with the marked cells, and the caller panel
+func drawCard() {
+	canvas.ReplacePixels(renderCard(card, caller).Pix)
+}
+
+// title returns the window title, with the completed patterns and the status
+func title(status string) string {
+	t := rules.Name + " Bingo"
 
-	for i := 0; i < 5; i++ {
-		nn := rand.Perm(15)[:5]
-		for _, n := range nn {
-			numbers = append(numbers, n+1+(i*15))
+	if won := card.Bingo(); len(won) > 0 {
+		var names []string
+		for _, p := range won {
+			names = append(names, p.Name)
 		}
-	}
 
-	for y := 0; y < vcount+1; y++ {
-		for x := 0; x < hcount; x++ {
-			if true {
-				inset.Fill(colors[x])
+		t += " - BINGO! " + strings.Join(names, ", ")
+	}
 
-				var str string
+	if status != "" {
+		t += " - " + status
+	}
 
-				if y == 0 { // letters
-					str = string("BINGO"[x])
-				} else {
-					i := x*hcount + y - 1
-					str = fmt.Sprintf("%d", numbers[i]+1)
-					if i == (vcount * hcount / 2) {
-						str = "*"
-					}
-				}
+	return t
+}
 
-				bound, _ := font.BoundString(ffont, str)
-				w := (bound.Max.X - bound.Min.X).Ceil()
-				h := (bound.Max.Y - bound.Min.Y).Ceil()
-				x := (tile.Bounds().Dx() - w) / 2
-				y := (tile.Bounds().Dy()-h)/2 + h
-				text.Draw(inset, str, ffont, x, y, textColor)
-			}
+func main() {
+	export := flag.Int("export", 0, "write this number of cards, without opening a window")
+	out := flag.String("out", "cards", "output directory for the exported cards (or a .pdf file, for a single document)")
+	seed := flag.Int64("seed", 0, "random seed (0: current time)")
+	flag.StringVar(&cardID, "card", "", "play the card with this ID")
+	host := flag.String("host", "", "host a network game, listening at this address (e.g. :7575)")
+	join := flag.String("join", "", "join the network game hosted at this address (host:port)")
+	name := flag.String("name", "", "player name, for network games")
+	variant := flag.String("rules", "75", "game rules: 75 (75-ball), 90 (90-ball UK tickets) or 30 (30-ball speed bingo)")
+	patterns := flag.String("patterns", "", "read the winning patterns from this file")
+	flag.Parse()
+
+	if r, ok := game.Variants[*variant]; ok {
+		rules = r
+	} else {
+		log.Fatalf("invalid rules %q", *variant)
+	}
 
-			op := &ebiten.DrawImageOptions{}
-			op.GeoM.Translate(float64(x*tw+border), float64(y*th+border))
-			canvas.DrawImage(tile, op)
+	if *patterns != "" {
+		list, err := game.ReadPatterns(*patterns, rules.W, rules.H)
+		if err != nil {
+			log.Fatal(err)
 		}
+
+		rules.Patterns = list
 	}
 
-	return ww, wh
-}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
 
-func main() {
-	rand.Seed(time.Now().Unix())
+	if *export > 0 {
+		if err := Export(*export, *out, *seed); err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	rng = rand.New(rand.NewSource(*seed))
 
 	ww, wh = initCanvas(ebiten.ScreenSizeInFullscreen())
 
-	ebiten.SetWindowTitle("Bingo")
+	g := &Game{}
+
+	switch {
+	case *host != "":
+		h, err := game.NewHost(*host, rules)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		defer h.Close()
+
+		g.host = h
+		g.status = "hosting at " + h.Addr()
+
+	case *join != "":
+		if *name == "" {
+			*name = "player " + card.ID
+		}
+
+		c, err := game.Join(*join, *name, card)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		defer c.Close()
+
+		g.client = c
+		g.status = "joined " + *join
+	}
+
+	ebiten.SetWindowTitle(title(g.status))
 	ebiten.SetWindowSize(ww, wh)
 	ebiten.SetFPSMode(ebiten.FPSModeVsyncOffMinimum)
-	ebiten.RunGame(&Game{})
+	ebiten.RunGame(g)
 }
 
-type Game struct{}
+type Game struct {
+	auto bool      // automatic caller
+	next time.Time // time of the next automatic call
+
+	host   *game.Host   // network game host (it calls the numbers)
+	client *game.Client // network game player (the host calls the numbers)
+	status string       // network status, shown in the title
+
+	disconnected bool // the connection to the host was lost (the card can still be marked, but not claimed)
+}
 
 func (g *Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
 	//fmt.Println("layout", outsideWidth, outsideHeight, "-", ww, wh)
@@ -153,13 +213,122 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	screen.DrawImage(canvas, noop)
 }
 
+// cellCoords returns the card cell at the screen position x,y (or -1, -1)
+func cellCoords(x, y int) (int, int) {
+	if x < border || y < border {
+		return -1, -1
+	}
+
+	x, y = (x-border)/tw, (y-border)/th-letterRows()
+
+	if x < 0 || y < 0 || x >= rules.W || y >= rules.H {
+		return -1, -1
+	}
+
+	return x, y
+}
+
+// call draws the next number (and sends it to the players, when hosting)
+func (g *Game) call() {
+	n, ok := caller.Draw()
+	if !ok {
+		g.auto = false
+	} else if g.host != nil {
+		g.host.Call(n)
+	}
+
+	g.next = time.Now().Add(autoDelay)
+	drawCard()
+}
+
+// receive handles the messages from the network
+func (g *Game) receive() {
+	var m game.Message
+	var ok bool
+
+	for {
+		switch {
+		case g.host != nil:
+			select {
+			case m = <-g.host.Events:
+			default:
+				return
+			}
+
+		case g.client != nil && !g.disconnected:
+			select {
+			case m, ok = <-g.client.Messages:
+				if !ok {
+					// stay a client: without the host nobody calls the numbers or validates the claims
+					g.client.Close()
+					g.disconnected = true
+					g.setStatus("disconnected from the host")
+					return
+				}
+
+			default:
+				return
+			}
+
+		default:
+			return
+		}
+
+		if m.Type == "number" {
+			caller.Add(m.Number)
+			drawCard()
+		} else {
+			g.setStatus(m.Describe(rules))
+		}
+	}
+}
+
+// setStatus shows the network status in the title
+func (g *Game) setStatus(status string) {
+	g.status = status
+	ebiten.SetWindowTitle(title(g.status))
+}
+
 func (g *Game) Update() error {
+	g.receive()
+
 	switch {
-	case inpututil.IsKeyJustPressed(ebiten.KeyR):
+	case g.host == nil && g.client == nil && inpututil.IsKeyJustPressed(ebiten.KeyR): // (R)estart
 		initCanvas(0, 0)
+		g.auto = false
+		ebiten.SetWindowTitle(title(g.status))
 
 	case inpututil.IsKeyJustPressed(ebiten.KeyQ), inpututil.IsKeyJustPressed(ebiten.KeyX):
 		return fmt.Errorf("quit")
+
+	case g.client != nil && inpututil.IsKeyJustPressed(ebiten.KeyB): // claim (B)ingo
+		if g.disconnected {
+			g.setStatus("disconnected from the host, can't claim")
+		} else if err := g.client.Claim(card); err != nil {
+			g.setStatus(err.Error())
+		}
+
+	case g.client != nil:
+		// the host calls the numbers (none after a disconnection)
+
+	case inpututil.IsKeyJustPressed(ebiten.KeySpace), inpututil.IsKeyJustPressed(ebiten.KeyN): // (N)ext number
+		g.call()
+
+	case inpututil.IsKeyJustPressed(ebiten.KeyA): // (A)utomatic caller
+		g.auto = !g.auto
+		g.next = time.Now()
+	}
+
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		x, y := cellCoords(ebiten.CursorPosition())
+		if x >= 0 && card.Mark(x, y, caller) {
+			drawCard()
+			ebiten.SetWindowTitle(title(g.status))
+		}
+	}
+
+	if g.auto && time.Now().After(g.next) {
+		g.call()
 	}
 
 	return nil
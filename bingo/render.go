@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"log"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/raff/ebi-games/bingo/game"
+)
+
+var small = basicfont.Face7x13 // font for the panel text
+
+// setLayout computes the card layout (tile and window size) for a w x h screen,
+// with square tiles for the grid defined by the rules
+func setLayout(w, h int) {
+	tw = min((w/2-border)/rules.W, (h/2-border)/rules.H)
+	th = tw
+
+	ww = tw*rules.W + border
+	wh = th*(letterRows()+rules.H+1) + border // letters, card and caller panel
+}
+
+// letterRows returns the number of rows above the card, for the column letters
+func letterRows() int {
+	if rules.Letters == "" {
+		return 0
+	}
+
+	return 1
+}
+
+// loadFont loads the font for the card numbers
+func loadFont() {
+	tt, err := opentype.Parse(gobold.TTF)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	const dpi = 72
+	ffont, err = opentype.NewFace(tt, &opentype.FaceOptions{
+		Size:    48,
+		DPI:     dpi,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// fill fills the rectangle r with color c (blending it, if c is translucent)
+func fill(img draw.Image, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Over)
+}
+
+// drawText draws str centered in the rectangle r
+func drawText(img draw.Image, r image.Rectangle, str string, clr color.Color) {
+	bound, _ := font.BoundString(ffont, str)
+	w := (bound.Max.X - bound.Min.X).Ceil()
+	h := (bound.Max.Y - bound.Min.Y).Ceil()
+
+	x := r.Min.X + (r.Dx()-w)/2
+	y := r.Min.Y + (r.Dy()-h)/2 + h
+
+	d := font.Drawer{Dst: img, Src: image.NewUniform(clr), Face: ffont, Dot: fixed.P(x, y)}
+	d.DrawString(str)
+}
+
+// drawLines draws the lines of text with the small font, starting at x,y
+func drawLines(img draw.Image, x, y int, lines []string, clr color.Color) {
+	d := font.Drawer{Dst: img, Src: image.NewUniform(clr), Face: small}
+
+	for _, l := range lines {
+		y += small.Metrics().Height.Ceil()
+
+		d.Dot = fixed.P(x, y)
+		d.DrawString(l)
+	}
+}
+
+// renderCard draws the card (with the marked cells) and the panel with the card ID
+// and the numbers called (if caller is not nil)
+func renderCard(card *game.Card, caller *game.Caller) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, ww, wh))
+	fill(img, img.Bounds(), background)
+
+	top := letterRows()
+
+	for y := 0; y < card.H+top; y++ {
+		for x := 0; x < card.W; x++ {
+			tile := image.Rect(0, 0, tw-border, th-border).Add(image.Pt(x*tw+border, y*th+border))
+			inset := tile.Inset(border)
+
+			fill(img, tile, borderColor)
+
+			var str string
+
+			if y < top { // letters
+				str = string(rules.Letters[x])
+			} else if n := card.Get(x, y-top); n == game.Blank {
+				fill(img, inset, background)
+				continue
+			} else if n == game.Free {
+				str = "*"
+			} else {
+				str = fmt.Sprintf("%d", n)
+			}
+
+			fill(img, inset, colors[x%len(colors)])
+			drawText(img, inset, str, textColor)
+
+			if y >= top && card.IsMarked(x, y-top) {
+				fill(img, tile, markColor)
+			}
+		}
+	}
+
+	py := (card.H+top)*th + border
+	px := border
+	lines := []string{"Card " + card.ID}
+
+	if caller != nil {
+		last := image.Rect(border, py, 2*tw, py+th-border)
+
+		switch {
+		case len(card.Bingo()) > 0:
+			drawText(img, last, "BINGO!", bingoColor)
+
+		case caller.Last() > 0:
+			drawText(img, last, rules.Label(caller.Last()), borderColor)
+		}
+
+		px = 2 * tw
+		lines = append(lines, called(caller, (ww-px-border)/7)...)
+	}
+
+	drawLines(img, px, py, lines, borderColor)
+	return img
+}
+
+// called returns the list of the numbers called, as lines of up to cols characters
+func called(caller *game.Caller, cols int) []string {
+	var lines []string
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "Called %d/%d:", len(caller.History), rules.Max)
+
+	for _, n := range caller.History {
+		l := " " + rules.Label(n)
+		if sb.Len()+len(l) > cols {
+			lines = append(lines, sb.String())
+			sb.Reset()
+		}
+
+		sb.WriteString(l)
+	}
+
+	return append(lines, sb.String())
+}
@@ -17,3 +17,5 @@ require (
 	golang.org/x/sync v0.3.0 // indirect
 	golang.org/x/sys v0.12.0 // indirect
 )
+
+replace github.com/raff/ebi-games/util v0.0.0-20240125044931-8bf78df1179d => ../util
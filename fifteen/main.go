@@ -3,18 +3,37 @@ package main
 import (
 	"bytes"
 	_ "embed"
+	"flag"
+	"fmt"
+	"image"
 	"image/color"
 	"log"
+	"math"
 	"math/rand"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/gobs/matrix"
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/raff/ebi-games/fifteen/solver"
 	"github.com/raff/ebi-games/util"
 )
 
 const (
 	border = 4
+
+	minSize = 3
+	maxSize = 8
+
+	maxTile = 96 // max tile size for the generated tiles
+
+	solveLimit = 50000000 // max boards to search for a solution
+	playDelay  = 15       // frames between moves, when playing the solution
+	animFrames = 8        // frames for a tile slide animation
 )
 
 var (
@@ -22,58 +41,454 @@ var (
 	tilesPng   []byte
 	tiles      *util.Tiles
 	background = color.NRGBA{64, 32, 64, 255}
+	tileColor  = color.NRGBA{160, 120, 64, 255}
+	edgeColor  = color.NRGBA{96, 64, 32, 255}
+	labelColor = color.NRGBA{0, 0, 0, 160}
+	hintColor  = color.NRGBA{0, 255, 0, 255}
+	overlay    = color.NRGBA{0, 0, 0, 192}
 )
 
-func readTiles() (int, int) {
+// readTiles reads the tiles for a cols x rows board, fitting the board in maxw x maxh.
+// With no image it uses the embedded 4x4 tiles, or plain numbered tiles for the other sizes.
+// If numbers is true the tile numbers are drawn over the image.
+func readTiles(path string, cols, rows int, numbers bool, maxw, maxh int) (int, int) {
 	var err error
 
-	tiles, err = util.ReadTiles(bytes.NewBuffer(tilesPng), 4, 4)
+	switch {
+	case path != "":
+		tiles, err = readImageTiles(path, cols, rows, maxw, maxh)
+
+	case cols == 4 && rows == 4:
+		tiles, err = util.ReadTiles(bytes.NewBuffer(tilesPng), 4, 4)
+		numbers = false // already there
+
+	default:
+		tiles = plainTiles(cols, rows, maxw, maxh)
+		numbers = true
+	}
+
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	last := len(tiles.List) - 1
+
+	if numbers {
+		for i, tile := range tiles.List[:last] {
+			drawNumber(tile, i+1)
+		}
+	}
+
 	// assuming the last tile is transparent (and empty) fill it with background color
-	tile := tiles.List[15]
+	tile := tiles.List[last]
 	tile.Fill(background)
 
 	// also, move last tile (empty) to zero position
-	tiles.List = append(tiles.List[15:], tiles.List[:15]...)
+	tiles.List = append(tiles.List[last:], tiles.List[:last]...)
 
 	return tiles.Width, tiles.Height
 }
 
+// readImageTiles slices a png or jpeg image in cols x rows tiles, scaled to fit in maxw x maxh
+func readImageTiles(path string, cols, rows int, maxw, maxh int) (*util.Tiles, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+
+	scale := math.Min(float64(maxw)/float64(config.Width), float64(maxh)/float64(config.Height))
+
+	tw := int(float64(config.Width) * scale / float64(cols))
+	th := int(float64(config.Height) * scale / float64(rows))
+
+	return util.ReadTilesScaledTo(bytes.NewReader(data), cols, rows, tw, th)
+}
+
+// plainTiles returns cols x rows square tiles, with no image
+func plainTiles(cols, rows int, maxw, maxh int) *util.Tiles {
+	size := maxTile
+	if s := maxw / cols; s < size {
+		size = s
+	}
+	if s := maxh / rows; s < size {
+		size = s
+	}
+
+	img := ebiten.NewImage(size*cols, size*rows)
+	img.Fill(edgeColor)
+
+	t := &util.Tiles{Width: size, Height: size, Columns: cols, Rows: rows}
+
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			r := image.Rect(x*size, y*size, (x+1)*size, (y+1)*size)
+			tile := img.SubImage(r).(*ebiten.Image)
+
+			tile.SubImage(r.Inset(2)).(*ebiten.Image).Fill(tileColor)
+			t.List = append(t.List, tile)
+		}
+	}
+
+	return t
+}
+
+// drawNumber draws the number n in the top left corner of the tile,
+// using the debug font scaled to about a third of the tile height
+func drawNumber(tile *ebiten.Image, n int) {
+	const lw, lh = 6, 16 // debug font character size
+
+	str := strconv.Itoa(n)
+
+	label := ebiten.NewImage(len(str)*lw+4, lh+2)
+	label.Fill(labelColor)
+	ebitenutil.DebugPrintAt(label, str, 2, 0)
+
+	bounds := tile.Bounds()
+	scale := float64(bounds.Dy() / 3 / lh)
+	if scale < 1 {
+		scale = 1
+	}
+
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(scale, scale)
+	op.GeoM.Translate(float64(bounds.Min.X+border), float64(bounds.Min.Y+border))
+	tile.DrawImage(label, op)
+}
+
 type Game struct {
 	redraw bool
 	ww, wh int
 	tw, th int
 
+	cols, rows int
+	image      string // image file, empty for the embedded tiles
+	numbers    bool   // draw the numbers over the image
+
 	cells matrix.Matrix[int]
 
+	solving bool          // the solver is running
+	result  chan solution // solver result
+	moves   []int         // solution moves to play (tiles to slide)
+	frame   int           // frames to the next move
+	hint    int           // tile to highlight, 0 for none
+	status  string        // shown in the window title
+
+	anims     []slideAnim // tiles moving
+	animFrame int         // frames since the moves started
+
+	playing bool      // scrambled, not solved yet
+	done    bool      // solved, show the overlay
+	helped  bool      // the solver was used (the result is not recorded)
+	nmoves  int       // player moves
+	start   time.Time // time of the first move
+	seconds int       // time since the first move
+
+	results  Results
+	rank     int  // position of the last result in the best results table
+	recorded bool // the last result was added to the table
+
 	canvas *ebiten.Image
 	drawOp ebiten.DrawImageOptions
 }
 
-func (g *Game) scramble(n int) {
-	var x, y int
+// A slideAnim is a tile moving from x,y to the (empty) cell at tx,ty
+type slideAnim struct {
+	v      int
+	x, y   int
+	tx, ty int
+}
+
+// A solution is the result of the solver for a board
+type solution struct {
+	board matrix.Matrix[int]
+	moves []int
+	err   error
+	play  bool // play the solution, instead of showing the hint
+}
+
+// solve runs the solver in the background, for the current board
+func (g *Game) solve(play bool) {
+	if g.solving {
+		return
+	}
+
+	board := g.cells.Clone()
+
+	g.solving = true
+	g.result = make(chan solution, 1)
+	g.setStatus("solving...")
+
+	go func(ch chan solution) {
+		moves, err := solver.Solve(board, solveLimit)
+		ch <- solution{board: board, moves: moves, err: err, play: play}
+	}(g.result)
+}
+
+// checkSolution gets the solver result, if it's ready and the board didn't change
+func (g *Game) checkSolution() {
+	var sol solution
+
+	select {
+	case sol = <-g.result:
+	default:
+		return
+	}
+
+	g.solving = false
+	g.setStatus("")
+
+	switch {
+	case sol.err != nil:
+		g.setStatus(sol.err.Error())
+
+	case !sol.board.Equals(g.cells):
+		// moved while solving, ignore
+
+	case len(sol.moves) == 0:
+		g.setStatus("solved")
+
+	case sol.play:
+		g.moves = sol.moves
+		g.frame = 0
+		g.setStatus(fmt.Sprintf("solution: %d moves", len(sol.moves)))
+
+	default:
+		g.hint = sol.moves[0]
+		g.setStatus(fmt.Sprintf("%d moves left", len(sol.moves)))
+		g.redraw = true
+	}
+}
+
+// setStatus shows the status in the window title
+func (g *Game) setStatus(status string) {
+	g.status = status
+	g.updateTitle()
+}
+
+// updateTitle shows the moves, time and status in the window title
+func (g *Game) updateTitle() {
+	title := fmt.Sprintf("%d Puzzle", g.cols*g.rows-1)
+
+	if g.playing || g.done {
+		title += fmt.Sprintf(" - moves: %d time: %v", g.nmoves, duration(g.seconds))
+	}
+
+	if g.status != "" {
+		title += " - " + g.status
+	}
+
+	ebiten.SetWindowTitle(title)
+}
+
+// sizeName returns the board size, used as the best results table key
+func (g *Game) sizeName() string {
+	return fmt.Sprintf("%dx%d", g.cols, g.rows)
+}
+
+// count counts n moves, starting the timer at the first one, and checks if the puzzle is solved
+func (g *Game) count(n int) {
+	if !g.playing {
+		return
+	}
+
+	if g.nmoves == 0 {
+		g.start = time.Now()
+	}
+
+	g.nmoves += n
+
+	if solver.Solved(g.cells) {
+		g.win()
+	}
+
+	g.updateTitle()
+}
+
+// updateTimer updates the time in the title, once a second
+func (g *Game) updateTimer() {
+	if !g.playing || g.nmoves == 0 {
+		return
+	}
+
+	if s := int(time.Since(g.start).Seconds()); s != g.seconds {
+		g.seconds = s
+		g.updateTitle()
+	}
+}
+
+// win stops the game and adds the result to the best results table (if the solver wasn't used)
+func (g *Game) win() {
+	g.seconds = int(time.Since(g.start).Seconds())
+	g.playing = false
+	g.done = true
+	g.recorded = false
+	g.redraw = true
+
+	if g.helped || g.results == nil {
+		return
+	}
+
+	g.rank = g.results.Add(g.sizeName(), g.seconds, g.nmoves)
+	g.recorded = true
+
+	if err := g.results.Save(); err != nil {
+		log.Println("cannot save best results:", err)
+	}
+}
+
+// newGame resets the counters. If scramble is true the tiles are shuffled, otherwise they are put in order.
+func (g *Game) newGame(scramble bool) {
+	g.stop()
+	g.anims = nil
+	g.animFrame = 0
+
+	if scramble {
+		g.scramble()
+	} else {
+		g.reset()
+	}
+
+	g.playing = scramble
+	g.done = false
+	g.helped = false
+	g.nmoves = 0
+	g.seconds = 0
+	g.status = ""
+	g.updateTitle()
+	g.redraw = true
+}
 
-zero:
-	for x = 0; x < g.cells.Width(); x++ {
-		for y = 0; y < g.cells.Height(); y++ {
-			if g.cells.Get(x, y) == 0 {
-				break zero
+// stop cancels the solution playback and the hint
+func (g *Game) stop() {
+	g.moves = nil
+	g.hint = 0
+}
+
+// find returns the position of tile v
+func (g *Game) find(v int) (int, int) {
+	for y := 0; y < g.cells.Height(); y++ {
+		for x := 0; x < g.cells.Width(); x++ {
+			if g.cells.Get(x, y) == v {
+				return x, y
 			}
 		}
 	}
 
-	for i := 0; i < n; i++ {
-		// list of neighbors (up, down, left, right)
-		list := g.cells.VonNewmann(x, y, false)
-		cell := list[rand.Intn(len(list))] // pick random neighbor
+	return -1, -1
+}
+
+// slide moves the tile at x,y into the empty cell, if they are next to each other
+func (g *Game) slide(x, y int) bool {
+	for _, cell := range g.cells.VonNewmann(x, y, false) {
+		if cell.Value == 0 { // swap select cell with empty one
+			v := g.cells.Get(x, y)
+
+			g.cells.Set(cell.X, cell.Y, v)
+			g.cells.Set(x, y, 0)
+			g.animate(slideAnim{v: v, x: x, y: y, tx: cell.X, ty: cell.Y})
+			g.redraw = true
+			return true
+		}
+	}
+
+	return false
+}
+
+// slideLine moves all the tiles between the empty cell and the one at x,y (included),
+// if they are in the same row or column. It returns the number of tiles moved.
+func (g *Game) slideLine(x, y int) int {
+	if x < 0 || y < 0 || x >= g.cells.Width() || y >= g.cells.Height() {
+		return 0
+	}
+
+	ex, ey := g.find(0)
+	if ex != x && ey != y {
+		return 0
+	}
+
+	n := 0
+
+	for ex != x || ey != y { // move the tile next to the empty cell, towards x,y
+		switch {
+		case x < ex:
+			ex--
+		case x > ex:
+			ex++
+		case y < ey:
+			ey--
+		default:
+			ey++
+		}
+
+		g.slide(ex, ey)
+		n++
+	}
+
+	return n
+}
+
+// move is a player move: it slides the tiles from x,y to the empty cell
+func (g *Game) move(x, y int) {
+	if n := g.slideLine(x, y); n > 0 {
+		g.stop()
+		g.count(n)
+	}
+}
+
+// animate adds a tile slide to the animation.
+// Slides in the same frame move together, while a new move ends the previous animation.
+func (g *Game) animate(a slideAnim) {
+	if g.animFrame > 0 {
+		g.anims = g.anims[:0]
+		g.animFrame = 0
+	}
+
+	g.anims = append(g.anims, a)
+}
+
+// updateAnimation advances the tile slide animation
+func (g *Game) updateAnimation() {
+	if len(g.anims) == 0 {
+		return
+	}
+
+	if g.animFrame++; g.animFrame >= animFrames {
+		g.anims = nil
+		g.animFrame = 0
+	}
+
+	g.redraw = true
+}
+
+// scramble shuffles the tiles with a uniformly random permutation.
+// Half of the permutations can't be solved: for those, swapping two tiles changes the parity
+// (and maps them one to one to the solvable ones, so the result is still uniform).
+func (g *Game) scramble() {
+	l := g.cells.Slice()
+
+	for {
+		copy(l, rand.Perm(len(l)))
 
-		g.cells.Set(x, y, cell.Value)
-		g.cells.Set(cell.X, cell.Y, 0)
+		if !solver.Solvable(g.cells) {
+			// swap the first two tiles that are not empty
+			i, j := 0, 1
+			if l[i] == 0 {
+				i = 2
+			} else if l[j] == 0 {
+				j = 2
+			}
 
-		x, y = cell.X, cell.Y
+			l[i], l[j] = l[j], l[i]
+		}
+
+		if !solver.Solved(g.cells) {
+			return
+		}
 	}
 }
 
@@ -93,7 +508,7 @@ func (g *Game) cellCoords(x, y int) (int, int) {
 	if x < border || y < border {
 		return -1, -1
 	}
-	if x > g.ww-border || y > g.wh-border {
+	if x >= g.ww-border || y >= g.wh-border {
 		return -1, -1
 	}
 
@@ -101,15 +516,15 @@ func (g *Game) cellCoords(x, y int) (int, int) {
 }
 
 func (g *Game) Init(screenw, screenh int) (int, int) {
-	g.tw, g.th = readTiles()
-	g.ww = g.tw*4 + border + border
-	g.wh = g.th*4 + border + border
+	g.tw, g.th = readTiles(g.image, g.cols, g.rows, g.numbers, screenw*2/3, screenh*2/3)
+	g.ww = g.tw*g.cols + border + border
+	g.wh = g.th*g.rows + border + border
 
 	g.canvas = ebiten.NewImage(g.ww, g.wh)
 	g.canvas.Fill(background)
 	g.redraw = true
 
-	g.cells = matrix.New[int](4, 4, false)
+	g.cells = matrix.New[int](g.cols, g.rows, false)
 	g.reset()
 	return g.ww, g.wh
 }
@@ -119,8 +534,13 @@ func (g *Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeigh
 }
 
 func (g *Game) drawCell(x, y, n int) {
+	g.drawTile(float64(x), float64(y), n)
+}
+
+// drawTile draws tile n at the (possibly fractional) cell position x,y
+func (g *Game) drawTile(x, y float64, n int) {
 	op := &ebiten.DrawImageOptions{}
-	op.GeoM.Translate(float64(x*g.tw+border), float64(y*g.th+border))
+	op.GeoM.Translate(x*float64(g.tw)+border, y*float64(g.th)+border)
 	g.canvas.DrawImage(tiles.List[n], op)
 }
 
@@ -136,38 +556,108 @@ func (g *Game) Draw(screen *ebiten.Image) {
 		}
 	}
 
+	if len(g.anims) > 0 {
+		t := float64(g.animFrame) / animFrames
+
+		for _, a := range g.anims { // the tiles are still on their way
+			g.drawCell(a.tx, a.ty, 0)
+		}
+
+		for _, a := range g.anims {
+			g.drawTile(float64(a.x)+t*float64(a.tx-a.x), float64(a.y)+t*float64(a.ty-a.y), a.v)
+		}
+	}
+
+	if g.hint > 0 {
+		x, y := g.find(g.hint)
+		vector.StrokeRect(g.canvas, float32(x*g.tw+border+1), float32(y*g.th+border+1), float32(g.tw-2), float32(g.th-2), 3, hintColor, false)
+	}
+
+	if g.done {
+		msg := fmt.Sprintf("SOLVED in %d moves, %v\n", g.nmoves, duration(g.seconds))
+		if g.recorded {
+			msg += "\n" + g.results.Table(g.sizeName(), g.rank)
+		} else if g.helped {
+			msg += "(with help, not recorded)\n"
+		}
+
+		vector.DrawFilledRect(g.canvas, float32(border), float32(border), float32(g.ww-2*border), float32(g.wh-2*border), overlay, false)
+		ebitenutil.DebugPrintAt(g.canvas, msg, 2*border, 2*border)
+	}
+
 	g.redraw = false
 	screen.DrawImage(g.canvas, &g.drawOp)
 }
 
 func (g *Game) Update() error {
+	if g.solving {
+		g.checkSolution()
+	}
+
+	g.updateTimer()
+	g.updateAnimation()
+
 	switch {
 	case inpututil.IsKeyJustPressed(ebiten.KeyQ), inpututil.IsKeyJustPressed(ebiten.KeyX): // (Q)uit or e(X)it
 		return ebiten.Termination
 
 	case inpututil.IsKeyJustPressed(ebiten.KeyR): // (R)andom
-		g.scramble(100)
-		g.redraw = true
+		g.newGame(true)
 
 	case inpututil.IsKeyJustPressed(ebiten.KeyI): // (I)nit
-		g.reset()
+		g.newGame(false)
+
+	case g.done:
+		// solved, wait for a new game
+
+	case inpututil.IsKeyJustPressed(ebiten.KeyS): // (S)olve
+		g.stop()
+		g.helped = true
+		g.solve(true)
 		g.redraw = true
 
+	case inpututil.IsKeyJustPressed(ebiten.KeyH): // (H)int
+		g.stop()
+		g.helped = true
+		g.solve(false)
+		g.redraw = true
+
+	case inpututil.IsKeyJustPressed(ebiten.KeyUp): // slide the tile below the empty cell
+		x, y := g.find(0)
+		g.move(x, y+1)
+
+	case inpututil.IsKeyJustPressed(ebiten.KeyDown): // slide the tile above the empty cell
+		x, y := g.find(0)
+		g.move(x, y-1)
+
+	case inpututil.IsKeyJustPressed(ebiten.KeyLeft): // slide the tile right of the empty cell
+		x, y := g.find(0)
+		g.move(x+1, y)
+
+	case inpututil.IsKeyJustPressed(ebiten.KeyRight): // slide the tile left of the empty cell
+		x, y := g.find(0)
+		g.move(x-1, y)
+
 	case inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft): // Mouse click
 		x, y := g.cellCoords(ebiten.CursorPosition())
 		if x < 0 {
 			break
 		}
 
-		list := g.cells.VonNewmann(x, y, false)
+		g.move(x, y)
+	}
 
-		for _, cell := range list {
-			if cell.Value == 0 { // swap select cell with empty one
-				//log.Println("swap", x, y, "with", cell)
-				g.cells.Set(cell.X, cell.Y, g.cells.Get(x, y))
-				g.cells.Set(x, y, 0)
-				g.redraw = true
-				break
+	if len(g.moves) > 0 {
+		if g.frame > 0 {
+			g.frame--
+		} else {
+			g.slide(g.find(g.moves[0]))
+			g.moves = g.moves[1:]
+			g.frame = playDelay
+			g.count(1)
+
+			if len(g.moves) == 0 && !g.done {
+				g.setStatus("solved")
 			}
 		}
 	}
@@ -175,15 +665,46 @@ func (g *Game) Update() error {
 	return nil
 }
 
+// parseSize parses a board size in the form NxM (columns x rows)
+func parseSize(s string) (int, int, error) {
+	var cols, rows int
+
+	if n, err := fmt.Sscanf(s, "%dx%d", &cols, &rows); err != nil || n != 2 {
+		return 0, 0, fmt.Errorf("invalid size %q: should be NxM", s)
+	}
+
+	if cols < minSize || rows < minSize || cols > maxSize || rows > maxSize {
+		return 0, 0, fmt.Errorf("invalid size %q: should be between %dx%d and %dx%d", s, minSize, minSize, maxSize, maxSize)
+	}
+
+	return cols, rows, nil
+}
+
 func main() {
-	g := &Game{}
+	size := flag.String("size", "4x4", "board size (columns x rows)")
+	imgfile := flag.String("image", "", "png or jpeg image to slice into tiles")
+	numbers := flag.Bool("numbers", false, "draw the tile numbers over the image")
+	flag.Parse()
+
+	cols, rows, err := parseSize(*size)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	g := &Game{cols: cols, rows: rows, image: *imgfile, numbers: *numbers}
 	ww, wh := ebiten.ScreenSizeInFullscreen()
 
-	ebiten.SetWindowTitle("15 Puzzle")
+	if res, err := LoadResults(); err != nil {
+		log.Println("cannot load best results:", err)
+	} else {
+		g.results = res
+	}
+
+	g.updateTitle()
 	ebiten.SetVsyncEnabled(false)
 	ebiten.SetScreenClearedEveryFrame(false)
 	ebiten.SetWindowSize(g.Init(ww, wh))
 	if err := ebiten.RunGame(g); err != nil {
-            log.Println(err)
-        }
+		log.Println(err)
+	}
 }
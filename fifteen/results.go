@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/raff/ebi-games/util"
+)
+
+const (
+	maxResults  = 10 // entries kept for each board size
+	showResults = 5  // entries shown after solving the puzzle
+)
+
+// A Result is an entry in the best results table
+type Result struct {
+	Seconds int       `json:"seconds"`
+	Moves   int       `json:"moves"`
+	Date    time.Time `json:"date"`
+}
+
+// Results stores the best results table for each board size
+type Results map[string][]Result
+
+// LoadResults reads the best results from the user config directory.
+// A missing file is not an error.
+func LoadResults() (Results, error) {
+	res := Results{}
+	err := util.LoadConfig("fifteen", &res)
+	return res, err
+}
+
+// Save writes the best results to the user config directory
+func (res Results) Save() error {
+	return util.SaveConfig("fifteen", res)
+}
+
+// Add adds a new result to the table for the board size (sorted by time, then moves).
+// It returns the position in the table, or -1 if the result didn't make it.
+func (res Results) Add(size string, seconds, moves int) int {
+	entry := Result{Seconds: seconds, Moves: moves, Date: time.Now()}
+
+	list, pos := util.AddBest(res[size], entry, maxResults, func(a, b Result) bool {
+		if a.Seconds != b.Seconds {
+			return a.Seconds < b.Seconds
+		}
+
+		return a.Moves < b.Moves
+	})
+
+	res[size] = list
+	return pos
+}
+
+// Table returns the top entries for the board size as text, marking the entry at position `mark`
+func (res Results) Table(size string, mark int) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "Best results %v\n", size)
+
+	for i, r := range res[size] {
+		if i >= showResults {
+			break
+		}
+
+		m := " "
+		if i == mark {
+			m = "*"
+		}
+
+		fmt.Fprintf(&sb, "%v%d. %v %4d moves %v\n", m, i+1, duration(r.Seconds), r.Moves, r.Date.Format("2006-01-02"))
+	}
+
+	return sb.String()
+}
+
+// duration formats the time in seconds as m:ss
+func duration(seconds int) string {
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
@@ -0,0 +1,300 @@
+// Package solver finds the optimal solution for a sliding puzzle ("15 puzzle") of any size,
+// using IDA* with the Manhattan distance and linear conflict heuristics.
+//
+// The board is a matrix.Matrix[int] where 0 is the empty cell, and the solved board
+// has the tiles 1..n-1 in row order, with the empty cell last.
+package solver
+
+import (
+	"errors"
+
+	"github.com/gobs/matrix"
+)
+
+var (
+	ErrUnsolvable = errors.New("the board is not solvable")
+	ErrTooHard    = errors.New("search limit reached")
+)
+
+// Solvable returns true if the board can be solved (half of the tile permutations can't).
+//
+// A horizontal move doesn't change the order of the tiles, while a vertical move changes the number
+// of inversions by width-1 and moves the empty cell one row. So for odd widths the parity of the inversions
+// never changes, and for even widths the parity of inversions + empty row never changes.
+func Solvable(b matrix.Matrix[int]) bool {
+	w, h := b.Width(), b.Height()
+	cells := b.Slice()
+
+	inversions := 0
+	empty := 0
+
+	for i, v := range cells {
+		if v == 0 {
+			empty = i / w
+			continue
+		}
+
+		for _, u := range cells[i+1:] {
+			if u != 0 && u < v {
+				inversions++
+			}
+		}
+	}
+
+	if w%2 != 0 {
+		return inversions%2 == 0
+	}
+
+	return (inversions+empty)%2 == (h-1)%2
+}
+
+// Solved returns true if the tiles are in order
+func Solved(b matrix.Matrix[int]) bool {
+	cells := b.Slice()
+
+	for i, v := range cells[:len(cells)-1] {
+		if v != i+1 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// search is the IDA* state: the board as a list of cells, with the heuristic values for each row and column
+type search struct {
+	w, h  int
+	cells []int
+	empty int // position of the empty cell
+
+	md    int   // sum of the Manhattan distances
+	lcRow []int // linear conflicts for each row
+	lcCol []int // linear conflicts for each column
+
+	nodes int
+	limit int
+
+	path []int // tiles moved
+}
+
+// goal returns the solved position of tile v
+func (s *search) goal(v int) (int, int) {
+	return (v - 1) % s.w, (v - 1) / s.w
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
+
+// distance returns the Manhattan distance of tile v at position p from its goal position
+func (s *search) distance(v, p int) int {
+	gx, gy := s.goal(v)
+	return abs(p%s.w-gx) + abs(p/s.w-gy)
+}
+
+// conflicts returns the minimum number of tiles to remove from a line so that the ones left
+// are in the right order. goals lists the goal positions, along the line, of the tiles that are already in their goal line.
+// The tiles left are the longest increasing subsequence of goals.
+func conflicts(goals []int) int {
+	longest := 0
+	lis := make([]int, len(goals)) // length of the longest increasing subsequence ending at i
+
+	for i, g := range goals {
+		lis[i] = 1
+
+		for j := 0; j < i; j++ {
+			if goals[j] < g && lis[j]+1 > lis[i] {
+				lis[i] = lis[j] + 1
+			}
+		}
+
+		if lis[i] > longest {
+			longest = lis[i]
+		}
+	}
+
+	return len(goals) - longest
+}
+
+// rowConflicts returns the linear conflicts for row y
+func (s *search) rowConflicts(y int) int {
+	var goals []int
+
+	for x := 0; x < s.w; x++ {
+		if v := s.cells[y*s.w+x]; v != 0 {
+			if gx, gy := s.goal(v); gy == y {
+				goals = append(goals, gx)
+			}
+		}
+	}
+
+	return conflicts(goals)
+}
+
+// colConflicts returns the linear conflicts for column x
+func (s *search) colConflicts(x int) int {
+	var goals []int
+
+	for y := 0; y < s.h; y++ {
+		if v := s.cells[y*s.w+x]; v != 0 {
+			if gx, gy := s.goal(v); gx == x {
+				goals = append(goals, gy)
+			}
+		}
+	}
+
+	return conflicts(goals)
+}
+
+// heuristic returns the estimated number of moves to solve the board (never more than the actual number).
+// Each linear conflict requires one of the tiles to leave the line and come back, so it adds 2 moves.
+func (s *search) heuristic() int {
+	lc := 0
+
+	for _, c := range s.lcRow {
+		lc += c
+	}
+	for _, c := range s.lcCol {
+		lc += c
+	}
+
+	return s.md + 2*lc
+}
+
+// move slides the tile at position p into the empty cell, updating the heuristic values
+func (s *search) move(p int) {
+	v := s.cells[p]
+
+	s.md += s.distance(v, s.empty) - s.distance(v, p)
+	s.cells[s.empty], s.cells[p] = v, 0
+
+	from, to := p, s.empty
+	s.empty = p
+
+	if from/s.w == to/s.w { // horizontal move: the tile changes column
+		s.lcCol[from%s.w] = s.colConflicts(from % s.w)
+		s.lcCol[to%s.w] = s.colConflicts(to % s.w)
+	} else { // vertical move: the tile changes row
+		s.lcRow[from/s.w] = s.rowConflicts(from / s.w)
+		s.lcRow[to/s.w] = s.rowConflicts(to / s.w)
+	}
+}
+
+// neighbors returns the positions of the tiles that can slide into the empty cell
+func (s *search) neighbors() []int {
+	list := make([]int, 0, 4)
+	x, y := s.empty%s.w, s.empty/s.w
+
+	if y > 0 {
+		list = append(list, s.empty-s.w)
+	}
+	if y < s.h-1 {
+		list = append(list, s.empty+s.w)
+	}
+	if x > 0 {
+		list = append(list, s.empty-1)
+	}
+	if x < s.w-1 {
+		list = append(list, s.empty+1)
+	}
+
+	return list
+}
+
+const found = -1
+
+// dfs searches for a solution within the bound, from a board reached with g moves.
+// It returns found, or the lowest f = g + h above the bound.
+func (s *search) dfs(g, bound, prev int) (int, error) {
+	h := s.heuristic()
+	if f := g + h; f > bound {
+		return f, nil
+	}
+
+	if h == 0 {
+		return found, nil
+	}
+
+	if s.nodes++; s.limit > 0 && s.nodes > s.limit {
+		return 0, ErrTooHard
+	}
+
+	next := -1
+
+	for _, p := range s.neighbors() {
+		if p == prev { // don't undo the last move
+			continue
+		}
+
+		v := s.cells[p]
+		empty := s.empty
+
+		s.move(p)
+		s.path = append(s.path, v)
+
+		t, err := s.dfs(g+1, bound, empty)
+		if err != nil || t == found {
+			return t, err
+		}
+
+		s.path = s.path[:len(s.path)-1]
+		s.move(empty)
+
+		if next < 0 || t < next {
+			next = t
+		}
+	}
+
+	return next, nil
+}
+
+// Solve returns the shortest list of moves that solves the board, as the list of tiles to slide into the empty cell.
+// It returns ErrUnsolvable if the board can't be solved, or ErrTooHard if the search visits more than
+// limit boards (0 for no limit). The board is not modified.
+func Solve(b matrix.Matrix[int], limit int) ([]int, error) {
+	if !Solvable(b) {
+		return nil, ErrUnsolvable
+	}
+
+	s := &search{
+		w:     b.Width(),
+		h:     b.Height(),
+		cells: append([]int(nil), b.Slice()...),
+		limit: limit,
+	}
+
+	s.lcRow = make([]int, s.h)
+	s.lcCol = make([]int, s.w)
+
+	for p, v := range s.cells {
+		if v == 0 {
+			s.empty = p
+		} else {
+			s.md += s.distance(v, p)
+		}
+	}
+
+	for y := range s.lcRow {
+		s.lcRow[y] = s.rowConflicts(y)
+	}
+	for x := range s.lcCol {
+		s.lcCol[x] = s.colConflicts(x)
+	}
+
+	for bound := s.heuristic(); ; {
+		t, err := s.dfs(0, bound, -1)
+		if err != nil {
+			return nil, err
+		}
+
+		if t == found {
+			return s.path, nil
+		}
+
+		bound = t
+	}
+}
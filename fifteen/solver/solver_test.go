@@ -0,0 +1,235 @@
+package solver
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gobs/matrix"
+)
+
+func board(t *testing.T, w int, cells ...int) matrix.Matrix[int] {
+	t.Helper()
+
+	b, ok := matrix.FromSlice(w, false, cells)
+	if !ok {
+		t.Fatalf("invalid board %v", cells)
+	}
+
+	return b
+}
+
+// korf returns one of Korf's 15 puzzle instances (where the empty cell goes first),
+// rotated by 180 degrees and with the tiles renumbered, so that the empty cell goes last
+func korf(t *testing.T, cells ...int) matrix.Matrix[int] {
+	rotated := make([]int, len(cells))
+
+	for p, v := range cells {
+		if v != 0 {
+			v = len(cells) - v
+		}
+
+		rotated[len(cells)-1-p] = v
+	}
+
+	return board(t, 4, rotated...)
+}
+
+// check verifies that the moves are legal and solve the board
+func check(t *testing.T, b matrix.Matrix[int], moves []int) {
+	t.Helper()
+
+	w := b.Width()
+	cells := append([]int(nil), b.Slice()...)
+
+	empty := 0
+	for p, v := range cells {
+		if v == 0 {
+			empty = p
+		}
+	}
+
+	for i, v := range moves {
+		p := -1
+		for q, u := range cells {
+			if u == v {
+				p = q
+			}
+		}
+
+		if d := abs(p%w-empty%w) + abs(p/w-empty/w); d != 1 {
+			t.Fatalf("move %d: tile %v is not next to the empty cell", i, v)
+		}
+
+		cells[empty], cells[p] = v, 0
+		empty = p
+	}
+
+	if !Solved(board(t, w, cells...)) {
+		t.Fatalf("not solved: %v", cells)
+	}
+}
+
+// longestIncreasing returns the length of the longest increasing subsequence, trying all of them
+func longestIncreasing(goals []int) int {
+	longest := 0
+
+	for mask := 0; mask < 1<<len(goals); mask++ {
+		n, last, ok := 0, -1, true
+
+		for i, g := range goals {
+			if mask&(1<<i) != 0 {
+				if g <= last {
+					ok = false
+					break
+				}
+
+				n, last = n+1, g
+			}
+		}
+
+		if ok && n > longest {
+			longest = n
+		}
+	}
+
+	return longest
+}
+
+func permutations(a []int, k int, f func([]int)) {
+	if k == len(a) {
+		f(a)
+		return
+	}
+
+	for i := k; i < len(a); i++ {
+		a[k], a[i] = a[i], a[k]
+		permutations(a, k+1, f)
+		a[k], a[i] = a[i], a[k]
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	tests := []struct {
+		goals []int
+		want  int
+	}{
+		{nil, 0},
+		{[]int{0, 1, 2}, 0},
+		{[]int{1, 0}, 1},
+		{[]int{2, 1, 0}, 2},
+		{[]int{1, 3, 0, 4, 2}, 2},
+		{[]int{3, 0, 1, 2}, 1},
+	}
+
+	for _, tt := range tests {
+		if got := conflicts(tt.goals); got != tt.want {
+			t.Errorf("conflicts(%v) = %d, want %d", tt.goals, got, tt.want)
+		}
+	}
+
+	for n := 1; n <= 8; n++ {
+		goals := make([]int, n)
+		for i := range goals {
+			goals[i] = i
+		}
+
+		permutations(goals, 0, func(goals []int) {
+			if got, want := conflicts(goals), n-longestIncreasing(goals); got != want {
+				t.Fatalf("conflicts(%v) = %d, want %d", goals, got, want)
+			}
+		})
+	}
+}
+
+func TestSolvable(t *testing.T) {
+	if !Solvable(board(t, 3, 1, 2, 3, 4, 5, 6, 7, 8, 0)) {
+		t.Error("solved 3x3 board is not solvable")
+	}
+
+	if Solvable(board(t, 3, 2, 1, 3, 4, 5, 6, 7, 8, 0)) {
+		t.Error("3x3 board with two tiles swapped is solvable")
+	}
+
+	if Solvable(board(t, 4, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 15, 14, 0)) {
+		t.Error("4x4 board with two tiles swapped is solvable")
+	}
+}
+
+func TestSolveUnsolvable(t *testing.T) {
+	_, err := Solve(board(t, 3, 2, 1, 3, 4, 5, 6, 7, 8, 0), 0)
+	if !errors.Is(err, ErrUnsolvable) {
+		t.Errorf("got %v, want %v", err, ErrUnsolvable)
+	}
+}
+
+func TestSolveTooHard(t *testing.T) {
+	_, err := Solve(board(t, 3, 8, 6, 7, 2, 5, 4, 3, 0, 1), 100)
+	if !errors.Is(err, ErrTooHard) {
+		t.Errorf("got %v, want %v", err, ErrTooHard)
+	}
+}
+
+func TestSolve(t *testing.T) {
+	tests := []struct {
+		name  string
+		board matrix.Matrix[int]
+		moves int
+		long  bool
+	}{
+		{"3x3 solved", board(t, 3, 1, 2, 3, 4, 5, 6, 7, 8, 0), 0, false},
+		{"3x3 one move", board(t, 3, 1, 2, 3, 4, 5, 6, 7, 0, 8), 1, false},
+		{"3x3 hardest 1", board(t, 3, 8, 6, 7, 2, 5, 4, 3, 0, 1), 31, false},
+		{"3x3 hardest 2", board(t, 3, 6, 4, 7, 8, 5, 0, 3, 2, 1), 31, false},
+		{"3x4", board(t, 3, 1, 2, 3, 4, 5, 6, 0, 8, 9, 7, 10, 11), 3, false},
+		{"korf 1", korf(t, 14, 13, 15, 7, 11, 12, 9, 5, 6, 0, 2, 1, 4, 8, 10, 3), 57, true},
+		{"korf 2", korf(t, 13, 5, 4, 10, 9, 12, 8, 14, 2, 3, 7, 1, 0, 15, 11, 6), 55, false},
+		{"korf 5", korf(t, 4, 7, 14, 13, 10, 3, 9, 12, 11, 5, 6, 15, 1, 2, 8, 0), 56, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.long && testing.Short() {
+				t.Skip("long search")
+			}
+
+			moves, err := Solve(tt.board, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(moves) != tt.moves {
+				t.Errorf("got %d moves, want %d", len(moves), tt.moves)
+			}
+
+			check(t, tt.board, moves)
+		})
+	}
+}
+
+// TestSolve5x5 checks the linear conflicts on a line of 5 tiles, where removing the tile with the most conflicts
+// first is not optimal: for the goals 1 3 0 4 2 it removes 3, 1 and 4, while removing 0 and 2 leaves 1 3 4 in order.
+// Counting 3 conflicts would make the heuristic overestimate.
+func TestSolve5x5(t *testing.T) {
+	b := board(t, 5,
+		2, 4, 1, 5, 3,
+		6, 7, 8, 9, 10,
+		11, 12, 13, 14, 15,
+		16, 17, 18, 19, 20,
+		21, 22, 23, 24, 0)
+
+	s := &search{w: 5, h: 5, cells: b.Slice()}
+	if got := s.rowConflicts(0); got != 2 {
+		t.Errorf("row conflicts = %d, want 2", got)
+	}
+
+	moves, err := Solve(b, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(moves) != 32 {
+		t.Errorf("got %d moves, want 32", len(moves))
+	}
+
+	check(t, b, moves)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/raff/ebi-games/util"
+)
+
+const (
+	maxBestTimes  = 10 // entries kept for each level
+	showBestTimes = 5  // entries shown after a win
+)
+
+// A BestTime is an entry in the best times table
+type BestTime struct {
+	Seconds int       `json:"seconds"`
+	Date    time.Time `json:"date"`
+}
+
+// BestTimes stores the best times table for each level
+type BestTimes map[string][]BestTime
+
+// LoadBestTimes reads the best times from the user config directory.
+// A missing file is not an error.
+func LoadBestTimes() (BestTimes, error) {
+	bt := BestTimes{}
+	err := util.LoadConfig("minesweeper", &bt)
+	return bt, err
+}
+
+// Save writes the best times to the user config directory
+func (bt BestTimes) Save() error {
+	return util.SaveConfig("minesweeper", bt)
+}
+
+// Add adds a new time to the table for the board (see Game.boardName).
+// It returns the position in the table, or -1 if the time didn't make it.
+func (bt BestTimes) Add(key string, seconds int) int {
+	entry := BestTime{Seconds: seconds, Date: time.Now()}
+
+	list, pos := util.AddBest(bt[key], entry, maxBestTimes, func(a, b BestTime) bool {
+		return a.Seconds < b.Seconds
+	})
+
+	bt[key] = list
+	return pos
+}
+
+// Table returns the top entries for the board as text, marking the entry at position `mark`
+func (bt BestTimes) Table(key string, mark int) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "Best times %v\n", key)
+
+	for i, t := range bt[key] {
+		if i >= showBestTimes {
+			break
+		}
+
+		m := " "
+		if i == mark {
+			m = "*"
+		}
+
+		fmt.Fprintf(&sb, "%v%d. %3ds %v\n", m, i+1, t.Seconds, t.Date.Format("2006-01-02"))
+	}
+
+	return sb.String()
+}
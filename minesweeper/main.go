@@ -3,9 +3,10 @@ package main
 import (
 	"bytes"
 	"flag"
-	//"fmt"
+	"fmt"
 	"image/color"
 	"log"
+	"math"
 	"math/rand"
 	"time"
 
@@ -13,12 +14,20 @@ import (
 
 	"github.com/gobs/matrix"
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/raff/ebi-games/minesweeper/solver"
 	"github.com/raff/ebi-games/util"
 )
 
 const (
 	border = 4
+
+	minWidth  = 8 // minimum board size, to fit the counters and the face
+	minHeight = 8
+
+	maxTime = 999 // the timer has only 3 digits
 )
 
 type State int
@@ -62,6 +71,24 @@ type Level struct {
 	mines  int
 }
 
+func (l Level) String() string {
+	return fmt.Sprintf("%dx%d/%d", l.width, l.height, l.mines)
+}
+
+// Check verifies that the board is big enough and that the mines fit in it,
+// leaving room for a safe first click.
+func (l Level) Check() error {
+	if l.width < minWidth || l.height < minHeight {
+		return fmt.Errorf("board too small: the minimum size is %dx%d", minWidth, minHeight)
+	}
+
+	if max := l.width*l.height - 9; l.mines < 1 || l.mines > max {
+		return fmt.Errorf("invalid number of mines: must be between 1 and %d", max)
+	}
+
+	return nil
+}
+
 var (
 	//go:embed assets/ms_cells.png
 	pngCells []byte
@@ -78,8 +105,18 @@ var (
 	tiles  *util.Tiles
 	digits *util.Tiles
 	faces  *util.Tiles
+	minus  *ebiten.Image // minus sign for the mine counter
+
+	background  = color.NRGBA{192, 192, 192, 255}
+	overlay     = color.NRGBA{0, 0, 0, 160}
+	hintColor   = color.NRGBA{0, 160, 0, 255}
+	cursorColor = color.NRGBA{0, 0, 255, 255}
+	hexColor    = color.NRGBA{128, 128, 128, 255} // hexagonal cells border
+	digitColor  = color.NRGBA{255, 0, 0, 255}
+
+	noguess = false // generate boards that can be solved without guessing
 
-	background = color.NRGBA{192, 192, 192, 255}
+	maxAttempts = 10000 // max number of boards to try in noguess mode
 
 	levels = []Level{
 		{9, 9, 10},
@@ -89,7 +126,8 @@ var (
 )
 
 type Game struct {
-	level Level
+	level    Level
+	topology Topology
 
 	cells matrix.Matrix[State]
 
@@ -97,11 +135,35 @@ type Game struct {
 	redraw bool
 	done   bool
 
-	state PlayState
+	state    PlayState
+	placed   bool // mines have been placed
+	chording bool // both mouse buttons are pressed
 
 	start   time.Time
+	stopped int64 // milliseconds at the end of the game
 	elapsed int
 
+	moves []Action // moves since the start of the game
+
+	replay  *SavedGame    // game being replayed
+	pending []Action      // replay moves still to play
+	clock   time.Duration // replay clock
+	paused  bool          // replay is paused
+
+	saveFile string
+
+	hint     bool // show hint
+	hx, hy   int  // hint cell
+	autoplay bool
+	helped   bool // hint or autoplay were used (the time is not recorded)
+
+	cursor bool // show keyboard cursor
+	kx, ky int  // keyboard cursor cell
+
+	best     BestTimes
+	bestRank int  // position of the last win in the best times table
+	recorded bool // the last win has been recorded
+
 	mx int // mine count area start (x)
 	my int // mine count area start (y)
 
@@ -120,7 +182,7 @@ type Game struct {
 	ww int // window width
 	wh int // window height
 
-	scale float64
+	scale  float64
 	drawOp ebiten.DrawImageOptions
 }
 
@@ -152,11 +214,25 @@ func (g *Game) Init(w, h int, scale float64) (int, int) {
 		if err != nil {
 			log.Fatal(err)
 		}
+
+		// there is no minus sign in the digits tiles
+		mh := digits.Height / 10
+		if mh < 2 {
+			mh = 2
+		}
+
+		minus = ebiten.NewImage(digits.Width, digits.Height)
+		minus.Fill(color.Black)
+		vector.DrawFilledRect(minus, 2, float32(digits.Height-mh)/2, float32(digits.Width-4), float32(mh), digitColor, false)
 	}
 
 	if g.ww == 0 {
 		g.cw = g.level.width * tiles.Width
-		g.ch = g.level.height * tiles.Height
+		g.ch = (g.level.height-1)*g.rowHeight() + tiles.Height
+
+		if g.level.height > 1 {
+			g.cw += g.rowOffset(1)
+		}
 
 		g.cx = border
 		g.cy = border + digits.Height + border
@@ -187,26 +263,401 @@ func (g *Game) Init(w, h int, scale float64) (int, int) {
 		}
 	}
 
-	states := g.cells.Slice()
-	perms := rand.Perm(len(states))
-
-	for i, p := range perms {
-		if p < g.level.mines {
-			states[i] = Mine
-		} else {
-			states[i] = Unchecked
-		}
-	}
+	// mines are placed on the first reveal, so that the first click is always safe
+	g.cells.Fill(Unchecked)
+	g.placed = false
 
 	g.start = time.Time{}
+	g.stopped = 0
 	g.elapsed = 0
+	g.recorded = false
+	g.moves = nil
 
 	g.state = Playing
 	g.redraw = true
 	g.done = false
+	g.hint = false
+	g.autoplay = false
+	g.helped = false
+
+	if g.replay != nil {
+		g.StartReplay()
+	}
+
 	return g.ww, g.wh
 }
 
+// placeMines places the mines randomly, keeping the cell at x,y and its neighbors clear.
+// In noguess mode it keeps trying until the board can be solved without guessing.
+func (g *Game) placeMines(x, y int) {
+	w, h := g.level.width, g.level.height
+
+	safe := map[int]bool{y*w + x: true}
+	for _, c := range g.topology.Neighbors(x, y) {
+		safe[c.Y*w+c.X] = true
+	}
+
+	var candidates []int
+	for i := 0; i < w*h; i++ {
+		if !safe[i] {
+			candidates = append(candidates, i)
+		}
+	}
+
+	if len(candidates) < g.level.mines { // no room for the mines: keep only the clicked cell clear
+		candidates = candidates[:0]
+
+		for i := 0; i < w*h; i++ {
+			if i != y*w+x {
+				candidates = append(candidates, i)
+			}
+		}
+	}
+
+	layout := make([]bool, w*h)
+
+	for attempt := 1; ; attempt++ {
+		for i := range layout {
+			layout[i] = false
+		}
+
+		for _, p := range rand.Perm(len(candidates))[:g.level.mines] {
+			layout[candidates[p]] = true
+		}
+
+		if !noguess || solver.Solvable(w, h, layout, x, y, g.topology.Neighbors) {
+			break
+		}
+
+		if attempt == maxAttempts {
+			log.Println("could not generate a no-guess board after", attempt, "attempts")
+			break
+		}
+	}
+
+	for i, m := range layout {
+		if !m {
+			continue
+		}
+
+		switch g.cells.Get(i%w, i/w) {
+		case Flag:
+			g.cells.Set(i%w, i/w, MineFlag)
+
+		case Unsure:
+			g.cells.Set(i%w, i/w, MineUnsure)
+
+		default:
+			g.cells.Set(i%w, i/w, Mine)
+		}
+	}
+
+	g.placed = true
+}
+
+// reveal uncovers the cell at x,y, expanding empty areas
+func (g *Game) reveal(x, y int) {
+	if !g.placed && g.cells.Get(x, y) == Unchecked {
+		g.placeMines(x, y)
+	}
+
+	switch g.cells.Get(x, y) {
+	case Unchecked:
+		count, cells := g.countMines(x, y)
+
+		if count > 0 {
+			s := Count1 + State(count-1)
+			g.cells.Set(x, y, s)
+		} else {
+			g.cells.Set(x, y, Empty)
+			g.expand(cells)
+		}
+
+		g.redraw = true
+
+	case Mine:
+		g.cells.Set(x, y, Exploded)
+		g.stopTimer()
+		g.state = Lost
+		g.redraw = true
+		g.done = true
+	}
+
+	g.checkWin()
+}
+
+// checkWin ends the game when all the cells that are not mines have been revealed
+func (g *Game) checkWin() {
+	if g.done || !g.placed {
+		return
+	}
+
+	states := g.cells.Slice()
+
+	for _, s := range states {
+		switch s {
+		case Unchecked, Flag, Unsure, UnsureChecked:
+			return
+		}
+	}
+
+	// flag the remaining mines, as in the original game
+	for i, s := range states {
+		switch s {
+		case Mine, MineUnsure, MineUnsureChecked:
+			states[i] = MineFlag
+		}
+	}
+
+	g.stopTimer()
+
+	g.state = Won
+	g.done = true
+	g.redraw = true
+}
+
+// mark cycles the cell at x,y through flag, unsure and unchecked
+func (g *Game) mark(x, y int) {
+	switch g.cells.Get(x, y) {
+	case Unchecked:
+		g.cells.Set(x, y, Flag)
+
+	case Flag:
+		g.cells.Set(x, y, Unsure)
+
+	case Unsure:
+		g.cells.Set(x, y, Unchecked)
+
+	case Mine:
+		g.cells.Set(x, y, MineFlag)
+
+	case MineFlag:
+		g.cells.Set(x, y, MineUnsure)
+
+	case MineUnsure:
+		g.cells.Set(x, y, Mine)
+
+	default:
+		return
+	}
+
+	g.redraw = true
+}
+
+// chord reveals all the unflagged neighbors of a numbered cell,
+// if the number of flags around it matches its count.
+// A wrong flag means a mine is revealed, and the game is lost.
+func (g *Game) chord(x, y int) {
+	s := g.cells.Get(x, y)
+	if s < Count1 || s > Count8 {
+		return
+	}
+
+	cells := g.neighbors(x, y)
+	flags := 0
+
+	for _, c := range cells {
+		if c.Value == Flag || c.Value == MineFlag {
+			flags++
+		}
+	}
+
+	if flags != int(s-Count1)+1 {
+		return
+	}
+
+	for _, c := range cells {
+		if g.done {
+			break
+		}
+
+		switch g.cells.Get(c.X, c.Y) {
+		case Unsure:
+			g.cells.Set(c.X, c.Y, Unchecked)
+
+		case MineUnsure:
+			g.cells.Set(c.X, c.Y, Mine)
+		}
+
+		g.reveal(c.X, c.Y)
+	}
+}
+
+// startTimer starts the timer on the first action
+func (g *Game) startTimer() {
+	if g.start.IsZero() {
+		g.start = time.Now()
+	}
+}
+
+// stopTimer stops the timer at the end of the game
+func (g *Game) stopTimer() {
+	g.stopped = g.now()
+	g.updateTimer()
+}
+
+// now returns the milliseconds since the start of the game.
+// The time stops at the end of the game, and in a replay it follows the replay clock.
+func (g *Game) now() int64 {
+	switch {
+	case g.start.IsZero():
+		return 0
+
+	case g.done:
+		return g.stopped
+
+	case g.replay != nil:
+		return g.clock.Milliseconds()
+	}
+
+	return time.Since(g.start).Milliseconds()
+}
+
+// Do executes a player move and adds it to the moves log
+func (g *Game) Do(kind ActionKind, x, y int) {
+	if g.done {
+		return
+	}
+
+	g.startTimer()
+	g.moves = append(g.moves, Action{Time: g.now(), Kind: kind, X: x, Y: y})
+
+	switch kind {
+	case Reveal:
+		g.reveal(x, y)
+
+	case Mark:
+		g.mark(x, y)
+
+	case Chord:
+		g.chord(x, y)
+	}
+}
+
+// saveGame writes the current game to the save file
+func (g *Game) saveGame() {
+	if err := g.Save().Write(g.saveFile); err != nil {
+		log.Println("cannot save game:", err)
+	} else {
+		log.Println("game saved to", g.saveFile)
+	}
+}
+
+// updateTimer updates the elapsed time while the game is in progress
+func (g *Game) updateTimer() {
+	if g.start.IsZero() || g.done {
+		return
+	}
+
+	elapsed := int(g.now() / 1000)
+	if elapsed > maxTime {
+		elapsed = maxTime
+	}
+
+	if elapsed != g.elapsed {
+		g.elapsed = elapsed
+		g.redraw = true
+	}
+}
+
+// boardName returns the level and topology (if not square), used as the best times table key
+func (g *Game) boardName() string {
+	if name := g.topology.Name(); name != "square" {
+		return g.level.String() + " " + name
+	}
+
+	return g.level.String()
+}
+
+// recordTime adds the time of a won game to the best times table
+func (g *Game) recordTime() {
+	if g.recorded || g.state != Won {
+		return
+	}
+
+	g.recorded = true
+
+	if g.helped || g.best == nil {
+		return
+	}
+
+	g.bestRank = g.best.Add(g.boardName(), g.elapsed)
+	if err := g.best.Save(); err != nil {
+		log.Println("cannot save best times:", err)
+	}
+
+	g.redraw = true
+}
+
+// board returns the player view of the game, for the solver.
+// Flags are not trusted, only the revealed counts.
+func (g *Game) board() *solver.Board {
+	b := solver.New(g.level.width, g.level.height, g.level.mines, g.topology.Neighbors)
+
+	for y := 0; y < g.level.height; y++ {
+		for x := 0; x < g.level.width; x++ {
+			switch s := g.cells.Get(x, y); {
+			case s == Empty:
+				b.Set(x, y, 0)
+
+			case s >= Count1 && s <= Count8:
+				b.Set(x, y, int(s-Count1)+1)
+			}
+		}
+	}
+
+	return b
+}
+
+// findHint sets the hint to the safest cell to reveal
+func (g *Game) findHint() {
+	if !g.placed {
+		// the first click is always safe
+		g.hx, g.hy = g.level.width/2, g.level.height/2
+		g.hint = true
+		return
+	}
+
+	if m, ok := g.board().Best(); ok {
+		g.hx, g.hy = m.X, m.Y
+		g.hint = true
+	}
+}
+
+// setMark cycles the mark on the cell at x,y until it is flagged (or not flagged)
+func (g *Game) setMark(x, y int, flagged bool) {
+	for i := 0; i < 3; i++ {
+		s := g.cells.Get(x, y)
+		if (s == Flag || s == MineFlag) == flagged && s != Unsure && s != MineUnsure {
+			return
+		}
+
+		g.Do(Mark, x, y)
+	}
+}
+
+// autoStep plays all the moves that can be deduced from the current board.
+// It returns false when there are no moves left without guessing.
+func (g *Game) autoStep() bool {
+	if !g.placed {
+		g.Do(Reveal, g.level.width/2, g.level.height/2)
+		return true
+	}
+
+	safe, mines := g.board().Deduce()
+
+	for _, p := range mines {
+		g.setMark(p.X, p.Y, true)
+	}
+
+	for _, p := range safe {
+		g.setMark(p.X, p.Y, false)
+		g.Do(Reveal, p.X, p.Y)
+	}
+
+	return len(safe) > 0
+}
+
 func (g *Game) FaceClicked(x, y int) bool {
 	x = int(float64(x) / g.scale)
 	y = int(float64(y) / g.scale)
@@ -229,22 +680,159 @@ func (g *Game) CellCoords(x, y int) (int, int) {
 	if x < g.cx || y < g.cy {
 		return -1, -1
 	}
-	if x > g.cx+g.cw || y > g.cy+g.ch {
+	if x >= g.cx+g.cw || y >= g.cy+g.ch {
 		return -1, -1
 	}
 
-	return (x - g.cx) / tiles.Width, g.cells.Fix((y - g.cy) / tiles.Height)
+	x, y = x-g.cx, y-g.cy
+
+	// hexagons overlap the row above, check both rows
+	row := y / g.rowHeight()
+
+	for _, r := range []int{row, row - 1} {
+		if r < 0 || r >= g.level.height {
+			continue
+		}
+
+		cx, cy := x-g.rowOffset(r), y-r*g.rowHeight()
+		if cx < 0 || cx >= g.level.width*tiles.Width || cy >= tiles.Height {
+			continue
+		}
+
+		if g.inCell(cx%tiles.Width, cy) {
+			return cx / tiles.Width, g.cells.Fix(r)
+		}
+	}
+
+	return -1, -1
+}
+
+// inCell returns true if the point x,y of a tile is inside the cell (always, unless the cells are hexagons)
+func (g *Game) inCell(x, y int) bool {
+	if !g.topology.Hexagons() {
+		return true
+	}
+
+	w, h := float64(tiles.Width), float64(tiles.Height)
+	px, py := float64(x)+0.5, float64(y)+0.5
+
+	// the slanted sides go from the top (or bottom) corner to a quarter of the height on the left and right sides
+	dx := math.Abs(px-w/2) / (w / 2)
+	dy := math.Min(py, h-py) / (h / 4)
+
+	return dx <= dy
+}
+
+// rowOffset returns the horizontal offset (in pixels) of row y: odd rows of hexagons are shifted by half a cell
+func (g *Game) rowOffset(y int) int {
+	if g.topology.Hexagons() && y%2 == 1 {
+		return tiles.Width / 2
+	}
+
+	return 0
+}
+
+// rowHeight returns the distance (in pixels) between rows: hexagons overlap the row above by a quarter of their height
+func (g *Game) rowHeight() int {
+	if g.topology.Hexagons() {
+		return tiles.Height * 3 / 4
+	}
+
+	return tiles.Height
+}
+
+// cellPos returns the position (top left corner of the tile) of the cell at x,y
+func (g *Game) cellPos(x, y int) (float32, float32) {
+	return float32(g.cx + g.rowOffset(y) + x*tiles.Width), float32(g.cy + y*g.rowHeight())
+}
+
+// hexagon returns the corners of the hexagonal cell at x,y, inset by d pixels
+func (g *Game) hexagon(x, y int, d float32) [6][2]float32 {
+	sx, sy := g.cellPos(x, y)
+	w, h := float32(tiles.Width), float32(tiles.Height)
+
+	return [6][2]float32{
+		{sx + w/2, sy + d},
+		{sx + w - d, sy + h/4 + d/2},
+		{sx + w - d, sy + h*3/4 - d/2},
+		{sx + w/2, sy + h - d},
+		{sx + d, sy + h*3/4 - d/2},
+		{sx + d, sy + h/4 + d/2},
+	}
+}
+
+// drawHex draws the tile img clipped to the hexagonal cell at x,y
+func (g *Game) drawHex(x, y int, img *ebiten.Image) {
+	var path vector.Path
+
+	corners := g.hexagon(x, y, 0)
+	for i, c := range corners {
+		if i == 0 {
+			path.MoveTo(c[0], c[1])
+		} else {
+			path.LineTo(c[0], c[1])
+		}
+	}
+
+	path.Close()
+
+	// map each corner to the same point of the tile
+	sx, sy := g.cellPos(x, y)
+	b := img.Bounds()
+
+	vs, is := path.AppendVerticesAndIndicesForFilling(nil, nil)
+	for i := range vs {
+		vs[i].SrcX = vs[i].DstX - sx + float32(b.Min.X)
+		vs[i].SrcY = vs[i].DstY - sy + float32(b.Min.Y)
+		vs[i].ColorR, vs[i].ColorG, vs[i].ColorB, vs[i].ColorA = 1, 1, 1, 1
+	}
+
+	g.canvas.DrawTriangles(vs, is, img, &ebiten.DrawTrianglesOptions{})
+	g.strokeHex(x, y, 0.5, 1, hexColor)
+}
+
+// strokeHex draws the border of the hexagonal cell at x,y, inset by d pixels
+func (g *Game) strokeHex(x, y int, d, width float32, c color.Color) {
+	corners := g.hexagon(x, y, d)
+
+	for i, p := range corners {
+		q := corners[(i+1)%len(corners)]
+		vector.StrokeLine(g.canvas, p[0], p[1], q[0], q[1], width, c, true)
+	}
+}
+
+// neighbors returns the cells adjacent to the one at x,y, according to the board topology
+func (g *Game) neighbors(x, y int) (cells []matrix.Cell[State]) {
+	for _, p := range g.topology.Neighbors(x, y) {
+		cells = append(cells, matrix.Cell[State]{X: p.X, Y: p.Y, Value: g.cells.Get(p.X, p.Y)})
+	}
+
+	return cells
 }
 
 func (g *Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
 	return g.ww, g.wh
 }
 
+// drawDigits draws a 3 digits counter. Negative values are shown with a minus sign (down to -99)
 func (g *Game) drawDigits(x, y, n int) {
 	op := &ebiten.DrawImageOptions{}
 	op.GeoM.Translate(float64(x), float64(y))
 
-	for x := 100; x > 0; x /= 10 {
+	start := 100
+
+	if n < 0 {
+		n = -n
+		if n > 99 {
+			n = 99
+		}
+
+		g.canvas.DrawImage(minus, op)
+		op.GeoM.Translate(float64(digits.Width), 0)
+		start = 10
+	}
+
+	for x := start; x > 0; x /= 10 {
 		d := (n / x) % 10
 
 		g.canvas.DrawImage(digits.Item(d), op)
@@ -252,70 +840,94 @@ func (g *Game) drawDigits(x, y, n int) {
 	}
 }
 
+// strokeCell draws a border around the cell at x,y
+func (g *Game) strokeCell(x, y int, c color.Color) {
+	if g.topology.Hexagons() {
+		g.strokeHex(x, y, 2, 2, c)
+		return
+	}
+
+	sx, sy := g.cellPos(x, y)
+
+	vector.StrokeRect(g.canvas, sx+1, sy+1, float32(tiles.Width-2), float32(tiles.Height-2), 2, c, false)
+}
+
 func (g *Game) Draw(screen *ebiten.Image) {
 	if !g.redraw {
 		return
 	}
 
 	op := &ebiten.DrawImageOptions{}
-	op.GeoM.Translate(float64(g.cx), float64(g.cy))
 
-	found := 0
-	wrong := 0
+	if g.topology.Hexagons() {
+		// the hexagons don't cover the whole cells area
+		vector.DrawFilledRect(g.canvas, float32(g.cx), float32(g.cy), float32(g.cw), float32(g.ch), background, false)
+	}
+
+	flags := 0
 
 	for y := 0; y < g.level.height; y++ {
 		for x := 0; x < g.level.width; x++ {
 			s := g.cells.Get(x, y)
+			if s == Flag || s == MineFlag {
+				flags++
+			}
+
 			if g.done {
 				switch s {
 				case Unchecked, Unsure:
 					s = Empty
 				case Flag:
 					s = Nomine
-					wrong++
-				case MineFlag:
-					found++
 				}
 			} else if s == Mine {
 				s = Unchecked
-			} else if s == Nomine {
-				wrong++
-			} else if s == Flag || s == MineFlag {
-				found++
 			}
 
-			g.canvas.DrawImage(tiles.List[s], op)
-			op.GeoM.Translate(float64(tiles.Width), 0)
-		}
+			if g.topology.Hexagons() {
+				g.drawHex(x, y, tiles.List[s])
+				continue
+			}
 
-		op.GeoM.SetElement(0, 2, border)
-		op.GeoM.Translate(0, float64(tiles.Height))
-	}
+			sx, sy := g.cellPos(x, y)
 
-	if !g.done && found == g.level.mines {
-		if wrong > 0 {
-			g.state = Lost
-		} else {
-			g.state = Won
+			op.GeoM.Reset()
+			op.GeoM.Translate(float64(sx), float64(sy))
+			g.canvas.DrawImage(tiles.List[s], op)
 		}
-
-		g.done = true
-		g.redraw = true
 	}
 
-	g.drawDigits(g.mx, g.my, found)
+	g.drawDigits(g.mx, g.my, g.level.mines-flags)
 	g.drawDigits(g.tx, g.ty, g.elapsed)
 
 	op.GeoM.Reset()
 	op.GeoM.Translate(float64(g.fx), float64(g.fy))
 	g.canvas.DrawImage(faces.List[g.state], op)
 
+	if g.cursor {
+		g.strokeCell(g.kx, g.ky, cursorColor)
+	}
+
+	if g.hint && !g.done {
+		g.strokeCell(g.hx, g.hy, hintColor)
+	}
+
+	if g.recorded && g.best != nil {
+		msg := g.best.Table(g.boardName(), g.bestRank)
+		if g.helped {
+			msg = "(with help, not recorded)\n" + g.best.Table(g.boardName(), -1)
+		}
+
+		vector.DrawFilledRect(g.canvas, float32(g.cx), float32(g.cy), float32(g.cw), float32(g.ch), overlay, false)
+		ebitenutil.DebugPrintAt(g.canvas, msg, g.cx+border, g.cy+border)
+	}
+
 	screen.DrawImage(g.canvas, &g.drawOp)
 }
 
 func (g *Game) countMines(x, y int) (int, []matrix.Cell[State]) {
 	count := 0
-	cells := g.cells.Moore(x, y, false)
+	cells := g.neighbors(x, y)
 
 	for _, c := range cells {
 		if c.Value == Mine || c.Value >= MineFlag {
@@ -344,6 +956,9 @@ func (g *Game) expand(cells []matrix.Cell[State]) {
 }
 
 func (g *Game) Update() error {
+	g.updateTimer()
+	g.recordTime()
+
 	switch {
 	case inpututil.IsKeyJustPressed(ebiten.KeyQ), inpututil.IsKeyJustPressed(ebiten.KeyX): // (Q)uit or e(X)it
 		return ebiten.Termination
@@ -351,6 +966,98 @@ func (g *Game) Update() error {
 	case inpututil.IsKeyJustPressed(ebiten.KeyR): // (R)edraw
 		g.Init(0, 0, 0)
 
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnter): // Restart (same as clicking the face)
+		g.Init(0, 0, 0)
+
+	case inpututil.IsKeyJustPressed(ebiten.KeyS): // (S)ave
+		g.saveGame()
+
+	case inpututil.IsKeyJustPressed(ebiten.KeyH): // (H)int pressed
+		g.findHint()
+		g.helped = g.helped || (g.hint && !g.done)
+		g.redraw = true
+
+	case inpututil.IsKeyJustReleased(ebiten.KeyH): // (H)int released
+		g.hint = false
+		g.redraw = true
+
+	case inpututil.IsKeyJustPressed(ebiten.KeyA): // (A)utoplay
+		g.autoplay = !g.autoplay
+		g.helped = g.helped || (g.autoplay && !g.done)
+
+	case g.replay != nil: // replay mode, no player moves
+		switch {
+		case inpututil.IsKeyJustPressed(ebiten.KeyP): // (P)ause
+			g.paused = !g.paused
+
+		case inpututil.IsKeyJustPressed(ebiten.KeyN): // (N)ext move
+			g.paused = true
+			g.stepReplay()
+		}
+
+		g.updateReplay()
+
+	case isKeyPressed(ebiten.KeyLeft):
+		g.moveCursor(-1, 0)
+
+	case isKeyPressed(ebiten.KeyRight):
+		g.moveCursor(1, 0)
+
+	case isKeyPressed(ebiten.KeyUp):
+		g.moveCursor(0, -1)
+
+	case isKeyPressed(ebiten.KeyDown):
+		g.moveCursor(0, 1)
+
+	case inpututil.IsKeyJustPressed(ebiten.KeySpace): // Reveal
+		if g.cursor {
+			g.Do(Reveal, g.kx, g.ky)
+		}
+
+	case inpututil.IsKeyJustPressed(ebiten.KeyF): // (F)lag
+		if g.cursor {
+			g.Do(Mark, g.kx, g.ky)
+		}
+
+	case inpututil.IsKeyJustPressed(ebiten.KeyD): // Chor(D)
+		if g.cursor {
+			g.Do(Chord, g.kx, g.ky)
+		}
+
+	case g.chording && (inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) ||
+		inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonRight)): // Chord (both buttons released)
+		g.chording = false
+		g.redraw = true
+
+		if g.done {
+			break
+		}
+
+		g.state = Playing
+
+		if x, y := g.CellCoords(ebiten.CursorPosition()); x >= 0 {
+			g.Do(Chord, x, y)
+		}
+
+	case !g.done && !g.chording &&
+		ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) &&
+		ebiten.IsMouseButtonPressed(ebiten.MouseButtonRight): // Both buttons pressed
+		g.chording = true
+		g.state = Surprise
+		g.redraw = true
+
+	case inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonMiddle): // Chord (middle click)
+		if g.done {
+			break
+		}
+
+		x, y := g.CellCoords(ebiten.CursorPosition())
+		if x < 0 {
+			break
+		}
+
+		g.Do(Chord, x, y)
+
 	case inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft): // Mouse release
 		if g.FaceClicked(ebiten.CursorPosition()) {
 			g.Init(0, 0, 0)
@@ -372,32 +1079,7 @@ func (g *Game) Update() error {
 			break
 		}
 
-		switch g.cells.Get(x, y) {
-		case Unchecked:
-			count, cells := g.countMines(x, y)
-
-			if count > 0 {
-				s := Count1 + State(count-1)
-				g.cells.Set(x, y, s)
-			} else {
-				g.cells.Set(x, y, Empty)
-				g.expand(cells)
-			}
-
-			g.redraw = true
-
-		case Mine:
-			g.cells.Set(x, y, Exploded)
-			g.state = Lost
-			g.redraw = true
-			g.done = true
-		}
-
-		if g.start.IsZero() {
-			g.start = time.Now()
-		} else {
-			g.elapsed = int(time.Now().Sub(g.start) / time.Second)
-		}
+		g.Do(Reveal, x, y)
 
 	case inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight): // Mouse click
 		if g.done {
@@ -409,45 +1091,69 @@ func (g *Game) Update() error {
 			break
 		}
 
-		switch g.cells.Get(x, y) {
-		case Unchecked:
-			g.cells.Set(x, y, Flag)
-			g.redraw = true
+		g.Do(Mark, x, y)
 
-		case Flag:
-			g.cells.Set(x, y, Unsure)
-			g.redraw = true
+	case g.autoplay:
+		if g.done || !g.autoStep() {
+			g.autoplay = false
+		}
+	}
 
-		case Unsure:
-			g.cells.Set(x, y, Unchecked)
-			g.redraw = true
+	return nil
+}
 
-		case Mine:
-			g.cells.Set(x, y, MineFlag)
-			g.redraw = true
+// moveCursor moves the keyboard cursor, wrapping around the board edges.
+// The first time the cursor is shown in the middle of the board.
+func (g *Game) moveCursor(dx, dy int) {
+	g.redraw = true
 
-		case MineFlag:
-			g.cells.Set(x, y, MineUnsure)
-			g.redraw = true
+	if !g.cursor {
+		g.cursor = true
+		g.kx, g.ky = g.level.width/2, g.level.height/2
+		return
+	}
 
-		case MineUnsure:
-			g.cells.Set(x, y, Mine)
-			g.redraw = true
-		}
+	g.kx = (g.kx + dx + g.level.width) % g.level.width
+	g.ky = (g.ky + dy + g.level.height) % g.level.height
+}
 
-		if g.start.IsZero() {
-			g.start = time.Now()
-		} else {
-			g.elapsed = int(time.Now().Sub(g.start) / time.Second)
-		}
+var keyPressed = map[ebiten.Key]bool{}
+
+func isKeyPressed(key ebiten.Key) bool {
+	const (
+		delay    = 10
+		interval = 3
+	)
+
+	if inpututil.IsKeyJustReleased(key) {
+		keyPressed[key] = false
+		return false
 	}
 
-	return nil
+	d := inpututil.KeyPressDuration(key)
+	if d > 0 && !keyPressed[key] {
+		keyPressed[key] = true
+		return true
+	}
+
+	if d >= delay && (d-delay)%interval == 0 {
+		return true
+	}
+
+	return false
 }
 
 func main() {
 	scale := flag.Float64("scale", 2, "Window scale")
 	level := flag.Int("level", 0, "0-beginner, 1-intermediat, 2-expert")
+	width := flag.Int("width", 0, "custom board width")
+	height := flag.Int("height", 0, "custom board height")
+	mines := flag.Int("mines", 0, "custom number of mines")
+	load := flag.String("load", "", "load a saved game")
+	replay := flag.String("replay", "", "replay a saved game")
+	save := flag.String("save", "minesweeper-save.json", "file used to save the game")
+	topology := flag.String("topology", "square", "board topology: square, torus, hex or knight")
+	flag.BoolVar(&noguess, "noguess", noguess, "generate boards that can be solved without guessing")
 	flag.Parse()
 
 	rand.Seed(time.Now().Unix())
@@ -459,11 +1165,75 @@ func main() {
 	}
 
 	g := &Game{level: levels[*level]}
+
+	if *width > 0 || *height > 0 || *mines > 0 {
+		// custom board, missing values come from the selected level
+		if *width > 0 {
+			g.level.width = *width
+		}
+		if *height > 0 {
+			g.level.height = *height
+		}
+		if *mines > 0 {
+			g.level.mines = *mines
+		}
+
+		if err := g.level.Check(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	g.saveFile = *save
+
+	var saved *SavedGame
+
+	if *load != "" || *replay != "" {
+		path := *load
+		if *replay != "" {
+			path = *replay
+		}
+
+		sg, err := ReadSavedGame(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		g.level = sg.Level()
+
+		if sg.Topology != "" {
+			*topology = sg.Topology
+		}
+
+		if *replay != "" {
+			g.replay = sg
+		} else {
+			saved = sg
+		}
+	}
+
+	t, err := NewTopology(*topology, g.level.width, g.level.height)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	g.topology = t
+
+	if best, err := LoadBestTimes(); err != nil {
+		log.Println("cannot load best times:", err)
+	} else {
+		g.best = best
+	}
+
 	ww, wh := ebiten.ScreenSizeInFullscreen()
 
 	ebiten.SetWindowTitle("Minesweeper")
 	ebiten.SetVsyncEnabled(false)
 	ebiten.SetScreenClearedEveryFrame(false)
 	ebiten.SetWindowSize(g.Init(ww, wh, *scale))
+
+	if saved != nil {
+		g.Restore(saved)
+	}
+
 	ebiten.RunGame(g)
 }
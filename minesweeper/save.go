@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+type ActionKind string
+
+const (
+	Reveal ActionKind = "reveal"
+	Mark   ActionKind = "flag" // cycle flag/unsure/unchecked
+	Chord  ActionKind = "chord"
+)
+
+// An Action is a player move, with the time (in milliseconds) since the start of the game
+type Action struct {
+	Time int64      `json:"time"`
+	Kind ActionKind `json:"kind"`
+	X    int        `json:"x"`
+	Y    int        `json:"y"`
+}
+
+// A SavedGame contains the board state and the list of moves.
+// It can be used to resume a game or to replay it.
+type SavedGame struct {
+	Width    int      `json:"width"`
+	Height   int      `json:"height"`
+	Mines    int      `json:"mines"`
+	Topology string   `json:"topology,omitempty"`
+	Placed   bool     `json:"placed"`  // mines have been placed
+	Elapsed  int64    `json:"elapsed"` // milliseconds
+	Cells    []State  `json:"cells"`
+	Moves    []Action `json:"moves"`
+}
+
+// ReadSavedGame reads a saved game from a file
+func ReadSavedGame(path string) (*SavedGame, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var sg SavedGame
+
+	if err := json.Unmarshal(data, &sg); err != nil {
+		return nil, err
+	}
+
+	if err := sg.Level().Check(); err != nil {
+		return nil, err
+	}
+
+	if len(sg.Cells) != sg.Width*sg.Height {
+		return nil, fmt.Errorf("invalid saved game: expected %d cells, got %d", sg.Width*sg.Height, len(sg.Cells))
+	}
+
+	mines := 0
+
+	for i, s := range sg.Cells {
+		if s < Unchecked || s > MineUnsureChecked {
+			return nil, fmt.Errorf("invalid saved game: cell %d,%d has invalid state %d", i%sg.Width, i/sg.Width, s)
+		}
+
+		if isMine(s) {
+			mines++
+		}
+	}
+
+	switch {
+	case sg.Placed && mines != sg.Mines:
+		return nil, fmt.Errorf("invalid saved game: expected %d mines, got %d", sg.Mines, mines)
+
+	case !sg.Placed && mines > 0:
+		return nil, fmt.Errorf("invalid saved game: %d mines, but the mines have not been placed", mines)
+	}
+
+	for i, a := range sg.Moves {
+		switch a.Kind {
+		case Reveal, Mark, Chord:
+		default:
+			return nil, fmt.Errorf("invalid saved game: move %d has unknown action %q", i+1, a.Kind)
+		}
+
+		if a.X < 0 || a.X >= sg.Width || a.Y < 0 || a.Y >= sg.Height {
+			return nil, fmt.Errorf("invalid saved game: move %d at %d,%d is outside the board", i+1, a.X, a.Y)
+		}
+	}
+
+	return &sg, nil
+}
+
+// Write writes the saved game to a file
+func (sg *SavedGame) Write(path string) error {
+	data, err := json.MarshalIndent(sg, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+// Level returns the level for the saved game
+func (sg *SavedGame) Level() Level {
+	return Level{width: sg.Width, height: sg.Height, mines: sg.Mines}
+}
+
+// isMine returns true if the cell state has a mine (flagged, marked unsure or exploded)
+func isMine(s State) bool {
+	switch s {
+	case Mine, Exploded, MineFlag, MineUnsure, MineUnsureChecked:
+		return true
+	}
+
+	return false
+}
+
+// Initial returns the board as it was before the first move: only the mines are set
+func (sg *SavedGame) Initial() []State {
+	cells := make([]State, len(sg.Cells))
+
+	for i, s := range sg.Cells {
+		if isMine(s) {
+			cells[i] = Mine
+		} else {
+			cells[i] = Unchecked
+		}
+	}
+
+	return cells
+}
+
+// Save returns the current game state
+func (g *Game) Save() *SavedGame {
+	sg := &SavedGame{
+		Width:    g.level.width,
+		Height:   g.level.height,
+		Mines:    g.level.mines,
+		Topology: g.topology.Name(),
+		Placed:   g.placed,
+		Cells:    append([]State(nil), g.cells.Slice()...),
+		Moves:    append([]Action(nil), g.moves...),
+	}
+
+	if !g.start.IsZero() {
+		sg.Elapsed = g.now()
+	}
+
+	return sg
+}
+
+// Restore sets the game state from a saved game (the level should already match)
+func (g *Game) Restore(sg *SavedGame) {
+	copy(g.cells.Slice(), sg.Cells)
+
+	g.placed = sg.Placed
+	g.moves = append([]Action(nil), sg.Moves...)
+
+	if len(sg.Moves) > 0 {
+		g.start = time.Now().Add(-time.Duration(sg.Elapsed) * time.Millisecond)
+		g.stopped = sg.Elapsed // used if the game is already over
+		g.elapsed = int(sg.Elapsed / 1000)
+	}
+
+	for _, s := range sg.Cells {
+		if s == Exploded {
+			g.state = Lost
+			g.done = true
+		}
+	}
+
+	g.checkWin()
+	g.recorded = g.done // don't add a restored game to the best times
+	g.redraw = true
+}
+
+// StartReplay resets the board to the initial state of the replayed game
+func (g *Game) StartReplay() {
+	copy(g.cells.Slice(), g.replay.Initial())
+
+	g.placed = g.replay.Placed
+	g.pending = g.replay.Moves
+	g.clock = 0
+	g.paused = false
+	g.recorded = true // don't add a replayed game to the best times
+}
+
+// stepReplay plays the next move in the replay
+func (g *Game) stepReplay() {
+	if len(g.pending) == 0 {
+		return
+	}
+
+	a := g.pending[0]
+	g.pending = g.pending[1:]
+	g.clock = time.Duration(a.Time) * time.Millisecond
+	g.Do(a.Kind, a.X, a.Y)
+}
+
+// updateReplay plays the moves that are due, according to the replay clock
+func (g *Game) updateReplay() {
+	if g.paused {
+		return
+	}
+
+	g.clock += time.Second / time.Duration(ebiten.TPS())
+
+	for len(g.pending) > 0 && time.Duration(g.pending[0].Time)*time.Millisecond <= g.clock {
+		g.stepReplay()
+	}
+}
@@ -0,0 +1,478 @@
+// Package solver implements a Minesweeper solver that only uses the information
+// available to the player: the revealed counts and the known mines.
+package solver
+
+import (
+	"sort"
+)
+
+const (
+	Unknown = -1 // cell not revealed yet
+	Flagged = -2 // cell known to be a mine
+)
+
+// A Point is a cell position on the board
+type Point struct {
+	X, Y int
+}
+
+// A NeighborsFunc returns the list of cells adjacent to the one at x,y
+type NeighborsFunc func(x, y int) []Point
+
+// Moore returns a NeighborsFunc for a w x h board where each cell has up to 8 neighbors
+func Moore(w, h int) NeighborsFunc {
+	return func(x, y int) (cells []Point) {
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				nx, ny := x+dx, y+dy
+
+				if (dx == 0 && dy == 0) || nx < 0 || ny < 0 || nx >= w || ny >= h {
+					continue
+				}
+
+				cells = append(cells, Point{nx, ny})
+			}
+		}
+
+		return cells
+	}
+}
+
+// A Board is the player view of a Minesweeper game
+type Board struct {
+	Width  int
+	Height int
+	Mines  int   // total number of mines
+	Cells  []int // Unknown, Flagged or the number of adjacent mines for revealed cells
+
+	Neighbors NeighborsFunc
+}
+
+// New creates a new board with all cells Unknown.
+// If neighbors is nil the Moore neighborhood is used.
+func New(w, h, mines int, neighbors NeighborsFunc) *Board {
+	if neighbors == nil {
+		neighbors = Moore(w, h)
+	}
+
+	b := &Board{
+		Width:     w,
+		Height:    h,
+		Mines:     mines,
+		Cells:     make([]int, w*h),
+		Neighbors: neighbors,
+	}
+
+	for i := range b.Cells {
+		b.Cells[i] = Unknown
+	}
+
+	return b
+}
+
+// Get returns the value for the cell at x,y
+func (b *Board) Get(x, y int) int {
+	return b.Cells[y*b.Width+x]
+}
+
+// Set changes the value for the cell at x,y
+func (b *Board) Set(x, y, v int) {
+	b.Cells[y*b.Width+x] = v
+}
+
+func (b *Board) point(i int) Point {
+	return Point{i % b.Width, i / b.Width}
+}
+
+func (b *Board) index(p Point) int {
+	return p.Y*b.Width + p.X
+}
+
+// A constraint says that exactly `mines` of the (unknown) `cells` are mines
+type constraint struct {
+	cells []int // sorted cell indices
+	mines int
+}
+
+// constraints returns the list of constraints for all the revealed cells
+// that are next to unknown cells (the "frontier")
+func (b *Board) constraints() (list []constraint) {
+	for i, v := range b.Cells {
+		if v < 0 {
+			continue
+		}
+
+		p := b.point(i)
+		c := constraint{mines: v}
+
+		for _, n := range b.Neighbors(p.X, p.Y) {
+			switch j := b.index(n); b.Cells[j] {
+			case Unknown:
+				c.cells = append(c.cells, j)
+
+			case Flagged:
+				c.mines--
+			}
+		}
+
+		if len(c.cells) > 0 {
+			sort.Ints(c.cells)
+			list = append(list, c)
+		}
+	}
+
+	return list
+}
+
+// subset returns the cells in b that are not in a, if a is a subset of b
+func subset(a, b []int) ([]int, bool) {
+	if len(a) >= len(b) {
+		return nil, false
+	}
+
+	var diff []int
+
+	i := 0
+	for _, v := range b {
+		if i < len(a) && a[i] == v {
+			i++
+		} else {
+			diff = append(diff, v)
+		}
+	}
+
+	return diff, i == len(a)
+}
+
+// clone returns a copy of the board
+func (b *Board) clone() *Board {
+	c := *b
+	c.Cells = append([]int(nil), b.Cells...)
+	return &c
+}
+
+// Deduce returns the unknown cells that are certainly safe and the ones that are certainly mines.
+// It applies the single-point rule (a count is satisfied by the known mines, or by all unknown neighbors)
+// and the subset rule (if the unknown neighbors of A are a subset of the unknown neighbors of B,
+// the difference contains B-A mines), repeating until no new mines are found.
+func (b *Board) Deduce() (safe, mines []Point) {
+	work := b.clone()
+
+	safes := map[int]bool{}
+	found := map[int]bool{}
+
+	for {
+		s, m := work.deduce()
+		if len(m) == 0 {
+			for c := range s {
+				safes[c] = true
+			}
+
+			break
+		}
+
+		for c := range m {
+			found[c] = true
+			work.Cells[c] = Flagged
+		}
+	}
+
+	return b.points(safes), b.points(found)
+}
+
+// deduce runs a single pass of Deduce, returning the sets of safe cells and mines
+func (b *Board) deduce() (map[int]bool, map[int]bool) {
+	safes := map[int]bool{}
+	found := map[int]bool{}
+
+	mark := func(cells []int, n int) {
+		switch n {
+		case 0:
+			for _, c := range cells {
+				safes[c] = true
+			}
+
+		case len(cells):
+			for _, c := range cells {
+				found[c] = true
+			}
+		}
+	}
+
+	cs := b.constraints()
+	touching := map[int][]int{} // cell -> constraints
+
+	for i, c := range cs {
+		mark(c.cells, c.mines)
+
+		for _, cell := range c.cells {
+			touching[cell] = append(touching[cell], i)
+		}
+	}
+
+	for i, a := range cs {
+		checked := map[int]bool{i: true}
+
+		for _, cell := range a.cells {
+			for _, j := range touching[cell] {
+				if checked[j] {
+					continue
+				}
+
+				checked[j] = true
+
+				if diff, ok := subset(a.cells, cs[j].cells); ok {
+					mark(diff, cs[j].mines-a.mines)
+				}
+			}
+		}
+	}
+
+	if len(safes) == 0 && len(found) == 0 {
+		// global rule: the number of remaining mines matches all or none of the unknown cells
+		var unknown []int
+
+		left := b.Mines
+		for i, v := range b.Cells {
+			switch v {
+			case Unknown:
+				unknown = append(unknown, i)
+
+			case Flagged:
+				left--
+			}
+		}
+
+		if len(unknown) > 0 {
+			mark(unknown, left)
+		}
+	}
+
+	return safes, found
+}
+
+// points returns the list of points for a set of cell indices, in board order
+func (b *Board) points(set map[int]bool) (list []Point) {
+	cells := make([]int, 0, len(set))
+	for c := range set {
+		cells = append(cells, c)
+	}
+
+	sort.Ints(cells)
+
+	for _, c := range cells {
+		list = append(list, b.point(c))
+	}
+
+	return list
+}
+
+// Probabilities returns the estimated probability for each cell to be a mine
+// (0 and 1 for the cells that can be deduced, -1 for revealed and flagged cells).
+//
+// For the cells next to a revealed count the estimate is the highest density of mines
+// among their constraints, while all the other unknown cells share the remaining mines.
+func (b *Board) Probabilities() []float64 {
+	probs := make([]float64, len(b.Cells))
+	for i := range probs {
+		probs[i] = -1
+	}
+
+	safe, mines := b.Deduce()
+
+	known := b.clone()
+
+	isSafe := map[int]bool{}
+
+	for _, p := range safe {
+		i := b.index(p)
+		isSafe[i] = true
+		probs[i] = 0
+	}
+
+	for _, p := range mines {
+		i := b.index(p)
+		known.Cells[i] = Flagged
+		probs[i] = 1
+	}
+
+	estimates := map[int]float64{}
+
+	for _, c := range known.constraints() {
+		var cells []int
+
+		for _, cell := range c.cells {
+			if !isSafe[cell] {
+				cells = append(cells, cell)
+			}
+		}
+
+		if len(cells) == 0 {
+			continue
+		}
+
+		p := float64(c.mines) / float64(len(cells))
+
+		for _, cell := range cells {
+			if old, ok := estimates[cell]; !ok || p > old {
+				estimates[cell] = p
+			}
+		}
+	}
+
+	left := float64(known.Mines)
+	var others []int
+
+	for i, v := range known.Cells {
+		switch {
+		case v == Flagged:
+			left--
+
+		case v != Unknown, isSafe[i]:
+			// known
+
+		default:
+			if p, ok := estimates[i]; ok {
+				probs[i] = p
+				left -= p
+			} else {
+				others = append(others, i)
+			}
+		}
+	}
+
+	if len(others) > 0 {
+		p := left / float64(len(others))
+		if p < 0 {
+			p = 0
+		} else if p > 1 {
+			p = 1
+		}
+
+		for _, i := range others {
+			probs[i] = p
+		}
+	}
+
+	return probs
+}
+
+// A Move is a suggested cell to reveal, with its probability of being a mine
+// (0 if the cell is certainly safe)
+type Move struct {
+	Point
+	Probability float64
+}
+
+// Best returns the safest cell to reveal: a cell that is certainly safe if there is one,
+// otherwise the cell with the lowest estimated probability of being a mine.
+// It returns false if there are no unknown cells left.
+func (b *Board) Best() (Move, bool) {
+	if safe, _ := b.Deduce(); len(safe) > 0 {
+		return Move{Point: safe[0]}, true
+	}
+
+	best := -1
+	probs := b.Probabilities()
+
+	for i, p := range probs {
+		if p < 0 || p == 1 {
+			continue
+		}
+
+		if best < 0 || p < probs[best] {
+			best = i
+		}
+	}
+
+	if best < 0 {
+		return Move{}, false
+	}
+
+	return Move{Point: b.point(best), Probability: probs[best]}, true
+}
+
+// Reveal uncovers the cell at x,y given the actual mine layout (indexed by y*w+x),
+// expanding all the cells with no adjacent mines.
+// It returns false if the cell is a mine.
+func (b *Board) Reveal(layout []bool, x, y int) bool {
+	if layout[b.index(Point{x, y})] {
+		return false
+	}
+
+	stack := []Point{{x, y}}
+
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		i := b.index(p)
+		if b.Cells[i] != Unknown {
+			continue
+		}
+
+		count := 0
+		neighbors := b.Neighbors(p.X, p.Y)
+
+		for _, n := range neighbors {
+			if layout[b.index(n)] {
+				count++
+			}
+		}
+
+		b.Cells[i] = count
+		if count == 0 {
+			stack = append(stack, neighbors...)
+		}
+	}
+
+	return true
+}
+
+// Cleared returns true if all the cells that are not mines have been revealed
+func (b *Board) Cleared() bool {
+	revealed := 0
+
+	for _, v := range b.Cells {
+		if v >= 0 {
+			revealed++
+		}
+	}
+
+	return revealed == len(b.Cells)-b.Mines
+}
+
+// Solvable returns true if the board with the given mine layout (indexed by y*w+x)
+// can be cleared starting at x,y using only deductions (no guessing).
+func Solvable(w, h int, layout []bool, x, y int, neighbors NeighborsFunc) bool {
+	mines := 0
+	for _, m := range layout {
+		if m {
+			mines++
+		}
+	}
+
+	b := New(w, h, mines, neighbors)
+	if !b.Reveal(layout, x, y) {
+		return false
+	}
+
+	for !b.Cleared() {
+		safe, found := b.Deduce()
+		if len(safe) == 0 && len(found) == 0 {
+			return false
+		}
+
+		for _, p := range found {
+			b.Set(p.X, p.Y, Flagged)
+		}
+
+		for _, p := range safe {
+			if !b.Reveal(layout, p.X, p.Y) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,206 @@
+package solver
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// parse returns a board from a list of rows, with one cell for each character:
+// ? for unknown cells, F for flagged cells and the count for revealed cells
+func parse(t *testing.T, mines int, rows ...string) *Board {
+	t.Helper()
+
+	b := New(len(rows[0]), len(rows), mines, nil)
+
+	for y, row := range rows {
+		for x, c := range row {
+			switch c {
+			case '?':
+				b.Set(x, y, Unknown)
+			case 'F':
+				b.Set(x, y, Flagged)
+			default:
+				n, err := strconv.Atoi(string(c))
+				if err != nil {
+					t.Fatalf("invalid cell %q", c)
+				}
+
+				b.Set(x, y, n)
+			}
+		}
+	}
+
+	return b
+}
+
+// layout returns a mine layout from a list of rows, where * is a mine
+func layout(rows ...string) (w, h int, mines []bool) {
+	for _, row := range rows {
+		for _, c := range row {
+			mines = append(mines, c == '*')
+		}
+	}
+
+	return len(rows[0]), len(rows), mines
+}
+
+func TestDeduce(t *testing.T) {
+	tests := []struct {
+		name  string
+		mines int
+		rows  []string
+		safe  []Point
+		found []Point
+	}{
+		{
+			name:  "single point: count satisfied by the flags",
+			mines: 5,
+			rows:  []string{"F1?"},
+			safe:  []Point{{2, 0}},
+		},
+		{
+			name:  "single point: all unknown neighbors are mines",
+			mines: 1,
+			rows:  []string{"?10"},
+			found: []Point{{0, 0}},
+		},
+		{
+			name:  "subset: 1-2-1",
+			mines: 2,
+			rows:  []string{"???", "121"},
+			safe:  []Point{{1, 0}},
+			found: []Point{{0, 0}, {2, 0}},
+		},
+		{
+			name:  "subset: 1-1-1",
+			mines: 1,
+			rows:  []string{"???", "111"},
+			safe:  []Point{{0, 0}, {2, 0}},
+		},
+		{
+			name:  "global: no mines left",
+			mines: 1,
+			rows:  []string{"F??"},
+			safe:  []Point{{1, 0}, {2, 0}},
+		},
+		{
+			name:  "global: all unknown cells are mines",
+			mines: 2,
+			rows:  []string{"??"},
+			found: []Point{{0, 0}, {1, 0}},
+		},
+		{
+			name:  "stuck",
+			mines: 2,
+			rows:  []string{"?1????"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := parse(t, tt.mines, tt.rows...)
+			before := append([]int(nil), b.Cells...)
+
+			safe, found := b.Deduce()
+
+			if !reflect.DeepEqual(safe, tt.safe) {
+				t.Errorf("safe = %v, want %v", safe, tt.safe)
+			}
+
+			if !reflect.DeepEqual(found, tt.found) {
+				t.Errorf("mines = %v, want %v", found, tt.found)
+			}
+
+			if !reflect.DeepEqual(b.Cells, before) {
+				t.Error("the board was modified")
+			}
+		})
+	}
+}
+
+func TestBest(t *testing.T) {
+	// a certainly safe cell
+	if m, ok := parse(t, 5, "F1?").Best(); !ok || m.Point != (Point{2, 0}) || m.Probability != 0 {
+		t.Errorf("got %v %v, want {2 0} with probability 0", m, ok)
+	}
+
+	// stuck: one mine in the first 3 cells (0.5 for 0 and 2), one in the other 3 (1/3 each)
+	b := parse(t, 2, "?1????")
+
+	m, ok := b.Best()
+	if !ok || m.Point != (Point{3, 0}) {
+		t.Errorf("got %v %v, want {3 0}", m, ok)
+	}
+
+	if p := m.Probability; p < 0.33 || p > 0.34 {
+		t.Errorf("probability = %v, want 1/3", p)
+	}
+
+	// nothing left to reveal
+	if m, ok := parse(t, 1, "F1").Best(); ok {
+		t.Errorf("got %v, want no move", m)
+	}
+}
+
+func TestSolvable(t *testing.T) {
+	// the bottom rows open up, then the 1-2-1 pattern finds the mines
+	w, h, mines := layout(
+		"*.*",
+		"...",
+		"...")
+
+	if !Solvable(w, h, mines, 1, 2, nil) {
+		t.Error("1-2-1 board is not solvable")
+	}
+
+	// the mine is in one of the top cells, both next to the same two 1s: a 50/50 guess
+	w, h, mines = layout(
+		"*.",
+		"..",
+		"..")
+
+	if Solvable(w, h, mines, 0, 2, nil) {
+		t.Error("50/50 board is solvable")
+	}
+
+	// starting on a mine
+	if Solvable(w, h, mines, 0, 0, nil) {
+		t.Error("board is solvable starting on a mine")
+	}
+}
+
+func TestReveal(t *testing.T) {
+	w, h, mines := layout(
+		"*..",
+		"...",
+		"...")
+
+	b := New(w, h, 1, nil)
+	if !b.Reveal(mines, 2, 2) {
+		t.Fatal("revealed a mine")
+	}
+
+	var rows []string
+	for y := 0; y < h; y++ {
+		var sb strings.Builder
+		for x := 0; x < w; x++ {
+			if v := b.Get(x, y); v == Unknown {
+				sb.WriteByte('?')
+			} else {
+				sb.WriteString(strconv.Itoa(v))
+			}
+		}
+
+		rows = append(rows, sb.String())
+	}
+
+	if want := []string{"?10", "110", "000"}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("got %v, want %v", rows, want)
+	}
+
+	if !b.Cleared() {
+		t.Error("board not cleared")
+	}
+}
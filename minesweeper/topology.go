@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/raff/ebi-games/minesweeper/solver"
+)
+
+// A Topology defines the board geometry: which cells are adjacent and the shape of the cells
+type Topology interface {
+	// Name returns the topology name, as used on the command line
+	Name() string
+
+	// Neighbors returns the cells adjacent to the one at x,y
+	Neighbors(x, y int) []solver.Point
+
+	// Hexagons returns true if the cells are hexagons (with pointy tops, and odd rows shifted right by half a cell)
+	Hexagons() bool
+}
+
+var topologies = []string{"square", "torus", "hex", "knight"}
+
+// NewTopology returns the named topology for a w x h board
+func NewTopology(name string, w, h int) (Topology, error) {
+	switch name {
+	case "", "square":
+		return square{w: w, h: h}, nil
+
+	case "torus":
+		return square{w: w, h: h, wrap: true}, nil
+
+	case "hex":
+		return hex{w: w, h: h}, nil
+
+	case "knight":
+		return knight{w: w, h: h}, nil
+	}
+
+	return nil, fmt.Errorf("invalid topology %q: should be one of %v", name, topologies)
+}
+
+// neighbors returns the cells at the given offsets from x,y that are inside the board
+// (or all of them, wrapping around the edges)
+func neighbors(w, h, x, y int, wrap bool, offsets [][2]int) (cells []solver.Point) {
+	for _, d := range offsets {
+		nx, ny := x+d[0], y+d[1]
+
+		if wrap {
+			nx = (nx + w) % w
+			ny = (ny + h) % h
+		} else if nx < 0 || ny < 0 || nx >= w || ny >= h {
+			continue
+		}
+
+		cells = append(cells, solver.Point{X: nx, Y: ny})
+	}
+
+	return cells
+}
+
+var mooreOffsets = [][2]int{
+	{-1, -1}, {0, -1}, {1, -1},
+	{-1, 0}, {1, 0},
+	{-1, 1}, {0, 1}, {1, 1},
+}
+
+// square is the classic board, with 8 neighbors. With wrap the edges are connected (torus)
+type square struct {
+	w, h int
+	wrap bool
+}
+
+func (t square) Name() string {
+	if t.wrap {
+		return "torus"
+	}
+
+	return "square"
+}
+
+func (t square) Neighbors(x, y int) []solver.Point {
+	return neighbors(t.w, t.h, x, y, t.wrap, mooreOffsets)
+}
+
+func (t square) Hexagons() bool {
+	return false
+}
+
+var (
+	// odd rows are shifted right by half a cell
+	hexEvenOffsets = [][2]int{{-1, -1}, {0, -1}, {-1, 0}, {1, 0}, {-1, 1}, {0, 1}}
+	hexOddOffsets  = [][2]int{{0, -1}, {1, -1}, {-1, 0}, {1, 0}, {0, 1}, {1, 1}}
+)
+
+// hex is a board of hexagonal cells (6 neighbors), stored as offset rows
+type hex struct {
+	w, h int
+}
+
+func (t hex) Name() string {
+	return "hex"
+}
+
+func (t hex) Neighbors(x, y int) []solver.Point {
+	if y%2 == 0 {
+		return neighbors(t.w, t.h, x, y, false, hexEvenOffsets)
+	}
+
+	return neighbors(t.w, t.h, x, y, false, hexOddOffsets)
+}
+
+func (t hex) Hexagons() bool {
+	return true
+}
+
+var knightOffsets = [][2]int{
+	{-1, -2}, {1, -2},
+	{-2, -1}, {2, -1},
+	{-2, 1}, {2, 1},
+	{-1, 2}, {1, 2},
+}
+
+// knight is a square board where the mines are counted a chess knight's move away
+type knight struct {
+	w, h int
+}
+
+func (t knight) Name() string {
+	return "knight"
+}
+
+func (t knight) Neighbors(x, y int) []solver.Point {
+	return neighbors(t.w, t.h, x, y, false, knightOffsets)
+}
+
+func (t knight) Hexagons() bool {
+	return false
+}
@@ -0,0 +1,47 @@
+// Command snakebench plays snake games with the autopilot bots, without opening a window,
+// and prints the average score and length for each bot.
+// It doesn't link ebiten, so it runs on headless machines.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/raff/ebi-games/snake/game"
+)
+
+const (
+	benchCols = 32 // board size, without level files
+	benchRows = 24
+)
+
+func main() {
+	games := flag.Int("bench", 100, "number of games for each bot")
+	wrap := flag.Bool("wrap", false, "the snake comes out on the opposite edge")
+	levels := flag.String("levels", "", "directory with the level files")
+	bots := flag.String("bot", "astar", "comma separated list of bots: "+game.BotNames())
+	seed := flag.Int64("seed", 0, "random seed (0: current time)")
+	flag.Parse()
+
+	if *seed == 0 {
+		*seed = time.Now().Unix()
+	}
+
+	layouts := []*game.Level{game.NewLevel(benchCols, benchRows, *wrap)}
+
+	if *levels != "" {
+		l, err := game.ReadLevels(*levels, *wrap)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		layouts = l
+	}
+
+	if err := game.Bench(os.Stdout, *games, strings.Split(*bots, ","), layouts, *seed); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,372 @@
+package game
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+var dirs = []Dir{Up, Down, Left, Right}
+
+// A Bot chooses the next direction for a player snake
+type Bot func(g *Game, p *Player) Dir
+
+// Bots are the available bots, by name
+var Bots = map[string]Bot{
+	"astar":    AStarBot,
+	"hamilton": HamiltonBot,
+}
+
+// BotNames returns the list of available bots
+func BotNames() string {
+	var names []string
+
+	for n := range Bots {
+		names = append(names, n)
+	}
+
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// occupancy returns, for each snake cell, the number of steps before a head can move into it.
+// A snake body moves one cell per step, starting from the tail.
+func occupancy(snakes ...[]Point) map[Point]int {
+	occ := map[Point]int{}
+
+	for _, cells := range snakes {
+		for i, c := range cells {
+			occ[c] = i + 2
+		}
+	}
+
+	return occ
+}
+
+// distance returns the manhattan distance between two cells (going around the edges if the level wraps)
+func (l *Level) distance(a, b Point) int {
+	dx, dy := a.X-b.X, a.Y-b.Y
+	if dx < 0 {
+		dx = -dx
+	}
+	if dy < 0 {
+		dy = -dy
+	}
+
+	if l.Wrap {
+		if l.W-dx < dx {
+			dx = l.W - dx
+		}
+		if l.H-dy < dy {
+			dy = l.H - dy
+		}
+	}
+
+	return dx + dy
+}
+
+type node struct {
+	p    Point
+	cost int // steps from start
+	prio int // cost + heuristic
+}
+
+type queue []node
+
+func (q queue) Len() int            { return len(q) }
+func (q queue) Less(i, j int) bool  { return q[i].prio < q[j].prio }
+func (q queue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *queue) Push(x interface{}) { *q = append(*q, x.(node)) }
+func (q *queue) Pop() interface{} {
+	old := *q
+	n := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return n
+}
+
+// path returns the shortest list of cells from `from` (excluded) to `to`, using A*.
+// A cell in occ can only be entered after the number of steps stored in occ.
+// It returns nil if there is no path.
+func (l *Level) path(from, to Point, occ map[Point]int) []Point {
+	prev := map[Point]Point{}
+	cost := map[Point]int{from: 0}
+
+	q := &queue{{p: from, prio: l.distance(from, to)}}
+
+	for q.Len() > 0 {
+		n := heap.Pop(q).(node)
+		if n.p == to {
+			var path []Point
+
+			for p := to; p != from; p = prev[p] {
+				path = append(path, p)
+			}
+
+			for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+				path[i], path[j] = path[j], path[i]
+			}
+
+			return path
+		}
+
+		if n.cost > cost[n.p] {
+			continue // stale entry
+		}
+
+		for _, d := range dirs {
+			p, ok := l.Step(n.p, d)
+			if !ok {
+				continue
+			}
+
+			c := n.cost + 1
+			if t, busy := occ[p]; busy && c < t {
+				continue
+			}
+
+			if old, seen := cost[p]; seen && old <= c {
+				continue
+			}
+
+			cost[p] = c
+			prev[p] = n.p
+			heap.Push(q, node{p: p, cost: c, prio: c + l.distance(p, to)})
+		}
+	}
+
+	return nil
+}
+
+// direction returns the direction to move from a to the adjacent cell b
+func (l *Level) direction(a, b Point) Dir {
+	for _, d := range dirs {
+		if p, _ := l.Step(a, d); p == b {
+			return d
+		}
+	}
+
+	return Nodir
+}
+
+// follow returns the snake cells after following the path (growing by one cell if it eats at the end)
+func follow(cells, path []Point, grow bool) []Point {
+	n := len(cells)
+	if grow {
+		n++
+	}
+
+	moved := append(append([]Point(nil), cells...), path...)
+	if len(moved) > n {
+		moved = moved[len(moved)-n:]
+	}
+
+	return moved
+}
+
+// safe returns true if the head of the snake can still reach its tail
+func (l *Level) safe(cells []Point) bool {
+	if len(cells) < 2 {
+		return true
+	}
+
+	head, tail := cells[len(cells)-1], cells[0]
+
+	occ := occupancy(cells[:len(cells)-1])
+	delete(occ, tail)
+
+	return l.path(head, tail, occ) != nil
+}
+
+// AStarBot moves towards the food along the shortest path, if after eating the snake can still reach its tail.
+// Otherwise it follows its tail, taking the longest way, hoping for a better opportunity.
+func AStarBot(g *Game, p *Player) Dir {
+	cells := p.Snake.Cells
+	head := p.Snake.Head()
+	occ := occupancy(cells)
+
+	if path := g.Level.path(head, g.Food.Point, occ); path != nil {
+		if g.Level.safe(follow(cells, path, true)) {
+			return g.Level.direction(head, path[0])
+		}
+	}
+
+	best, longest := Nodir, -1
+
+	for _, d := range dirs {
+		next, ok := g.Level.Step(head, d)
+		if !ok || occ[next] > 1 {
+			continue
+		}
+
+		moved := follow(cells, []Point{next}, next == g.Food.Point)
+		if !g.Level.safe(moved) {
+			if best == Nodir {
+				best = d // better than crashing
+			}
+
+			continue
+		}
+
+		tocc := occupancy(moved[:len(moved)-1])
+		delete(tocc, moved[0])
+
+		if l := len(g.Level.path(next, moved[0], tocc)); l > longest {
+			best, longest = d, l
+		}
+	}
+
+	if best == Nodir {
+		return p.Dir
+	}
+
+	return best
+}
+
+var cycles = map[*Level]map[Point]Dir{}
+
+// hamiltonCycle returns the direction to follow from each cell to visit all the board cells in a cycle.
+// It returns nil if the level has walls or both sides are odd (there is no simple cycle).
+func hamiltonCycle(l *Level) map[Point]Dir {
+	if c, ok := cycles[l]; ok {
+		return c
+	}
+
+	var next map[Point]Dir
+
+	switch {
+	case len(l.Walls) > 0 || l.W < 2 || l.H < 2:
+		// no cycle
+
+	case l.H%2 == 0: // row by row, going back down on the first column
+		next = map[Point]Dir{}
+
+		for y := 0; y < l.H; y++ {
+			for x := 1; x < l.W; x++ {
+				switch {
+				case y%2 == 0 && x < l.W-1:
+					next[Point{x, y}] = Right
+				case y%2 == 0:
+					next[Point{x, y}] = Up
+				case x > 1:
+					next[Point{x, y}] = Left
+				case y < l.H-1:
+					next[Point{x, y}] = Up
+				default:
+					next[Point{x, y}] = Left
+				}
+			}
+		}
+
+		for y := 1; y < l.H; y++ {
+			next[Point{0, y}] = Down
+		}
+
+		next[Point{0, 0}] = Right
+
+	case l.W%2 == 0: // column by column, going back left on the first row
+		next = map[Point]Dir{}
+
+		for x := 0; x < l.W; x++ {
+			for y := 1; y < l.H; y++ {
+				switch {
+				case x%2 == 0 && y < l.H-1:
+					next[Point{x, y}] = Up
+				case x%2 == 0:
+					next[Point{x, y}] = Right
+				case y > 1:
+					next[Point{x, y}] = Down
+				case x < l.W-1:
+					next[Point{x, y}] = Right
+				default:
+					next[Point{x, y}] = Down
+				}
+			}
+		}
+
+		for x := 1; x < l.W; x++ {
+			next[Point{x, 0}] = Left
+		}
+
+		next[Point{0, 0}] = Up
+	}
+
+	cycles[l] = next
+	return next
+}
+
+// HamiltonBot follows a cycle that visits all the cells, so it never crashes (but it is slow).
+// If there is no cycle for the level it falls back to AStarBot.
+func HamiltonBot(g *Game, p *Player) Dir {
+	cycle := hamiltonCycle(g.Level)
+	if cycle == nil {
+		return AStarBot(g, p)
+	}
+
+	return cycle[p.Snake.Head()]
+}
+
+// Bench plays n games for each bot and writes the average score and length to w
+func Bench(w io.Writer, n int, names []string, levels []*Level, seed int64) error {
+	for _, name := range names {
+		bot, ok := Bots[name]
+		if !ok {
+			return fmt.Errorf("unknown bot %q: should be one of %v", name, BotNames())
+		}
+
+		score, length, best := 0, 0, 0
+
+		for i := 0; i < n; i++ {
+			g := &Game{Levels: levels, Players: []*Player{{}}, Seed: seed + int64(i)}
+			g.SetLevel(i % len(levels))
+			g.Start(true)
+
+			s, l := g.Play(bot)
+
+			score += s
+			length += l
+			if s > best {
+				best = s
+			}
+		}
+
+		fmt.Fprintf(w, "%-10v games: %v avg score: %.2f avg length: %.2f best score: %v\n",
+			name, n, float64(score)/float64(n), float64(length)/float64(n), best)
+	}
+
+	return nil
+}
+
+// Play plays a single game (one life) with the bot, until the snake crashes, starves or fills the board.
+// It returns the score and the snake length.
+func (g *Game) Play(bot Bot) (int, int) {
+	p := g.Players[0]
+
+	free := g.Level.W*g.Level.H - len(g.Level.Walls)
+	starve := 0
+
+	for starve < 2*free {
+		p.Dir = bot(g, p)
+
+		switch p.Snake.Move(p.Dir, g.Level, g.Food.Point) {
+		case Wall, Body:
+			return p.Score, len(p.Snake.Cells)
+
+		case Food:
+			p.Score++
+			starve = 0
+
+			if len(p.Snake.Cells) >= free { // board full
+				return p.Score, len(p.Snake.Cells)
+			}
+
+			g.SpawnFood(Apple) // no power-ups, to keep the scores comparable
+
+		default:
+			starve++
+		}
+	}
+
+	return p.Score, len(p.Snake.Cells)
+}
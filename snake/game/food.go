@@ -0,0 +1,132 @@
+package game
+
+// FoodKind is the kind of food (regular food, bonus fruit or power-up)
+type FoodKind int
+
+const (
+	Apple  FoodKind = iota // regular food
+	Bonus                  // timed fruit, worth more points
+	Slow                   // slows down the game for a while
+	Shrink                 // removes some segments from the tail
+	Ghost                  // the snake can pass through its own body for a while
+)
+
+// A FoodType describes how a kind of food scores, spawns and what it does
+// (the colors are in the game UI)
+type FoodType struct {
+	name   string
+	score  int
+	weight int // spawn probability, relative to the other types
+	ttl    int // ticks before the food vanishes, 0 for never
+	amount int // effect: ticks for Slow and Ghost, segments for Shrink
+}
+
+var foodTypes = []FoodType{
+	Apple:  {name: "apple", score: 1, weight: 80},
+	Bonus:  {name: "bonus", score: 5, weight: 8, ttl: 40},
+	Slow:   {name: "slow", score: 1, weight: 4, ttl: 60, amount: 50},
+	Shrink: {name: "shrink", score: 1, weight: 4, ttl: 60, amount: 3},
+	Ghost:  {name: "ghost", score: 1, weight: 4, ttl: 60, amount: 30},
+}
+
+const slowDown = 3 // speed decrease while Slow is active
+
+// A FoodItem is the food on the board
+type FoodItem struct {
+	Point
+	Kind FoodKind
+	ttl  int // ticks left before it vanishes, 0 for never
+}
+
+// RandKind returns a random food kind, according to the spawn probabilities
+func (g *Game) RandKind() FoodKind {
+	total := 0
+	for _, ft := range foodTypes {
+		total += ft.weight
+	}
+
+	n := g.rng.Intn(total)
+
+	for k, ft := range foodTypes {
+		if n < ft.weight {
+			return FoodKind(k)
+		}
+
+		n -= ft.weight
+	}
+
+	return Apple
+}
+
+// SpawnFood places a new food of the given kind at a random position
+func (g *Game) SpawnFood(kind FoodKind) {
+	g.Food.X, g.Food.Y = g.RandFood()
+	g.Food.Kind = kind
+	g.Food.ttl = foodTypes[kind].ttl
+}
+
+// Eat applies the score and effect of the food to the player that ate it, and spawns a new food
+func (g *Game) Eat(p *Player) {
+	ft := foodTypes[g.Food.Kind]
+
+	p.Score += ft.score
+
+	switch g.Food.Kind {
+	case Slow:
+		g.slow = ft.amount
+
+	case Shrink:
+		p.Snake.Shrink(ft.amount)
+
+	case Ghost:
+		p.Ghost = ft.amount
+	}
+
+	g.starve++
+	if g.starve >= g.eats && g.speed < MaxSpeed {
+		g.speed++
+		g.starve = 0
+		g.eats += 2
+	}
+
+	g.eaten++
+	g.SpawnFood(g.RandKind())
+}
+
+// Expire counts down the timed food and the active effects.
+// It returns true if the slow effect ended (the speed changed).
+func (g *Game) Expire() bool {
+	if g.Food.ttl > 0 {
+		if g.Food.ttl--; g.Food.ttl == 0 { // vanished, replace with regular food
+			g.SpawnFood(Apple)
+		}
+	}
+
+	changed := false
+
+	if g.slow > 0 {
+		g.slow--
+		changed = g.slow == 0
+	}
+
+	for _, p := range g.Players {
+		if p.Ghost > 0 {
+			p.Ghost--
+		}
+	}
+
+	return changed
+}
+
+// Speed returns the current speed, lowered while Slow is active (down to 1, the slowest)
+func (g *Game) Speed() int {
+	if g.slow == 0 {
+		return g.speed
+	}
+
+	if s := g.speed - slowDown; s > 1 {
+		return s
+	}
+
+	return 1
+}
@@ -0,0 +1,286 @@
+// Package game is the snake game logic: levels, snakes, food and bots.
+// It doesn't depend on ebiten, and it is deterministic: the same seed and the same turns, one per tick,
+// replay the same game.
+package game
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+const MaxSpeed = 10
+
+// State is the game state, after a tick
+type State int
+
+const (
+	Running       State = iota
+	Crashed             // a snake crashed, the players have lives left
+	Over                // a player has no lives left
+	LevelComplete       // the level goal was reached
+)
+
+type Game struct {
+	Players []*Player
+	Food    FoodItem
+	slow    int // ticks left with slow power-up
+
+	Levels []*Level // level layouts
+	NLevel int      // current level
+	Level  *Level
+	eaten  int // food eaten in the current level
+
+	State   State
+	Crashed []int // players that crashed in the last tick
+
+	Bot       Bot  // autopilot for the first player
+	Autopilot bool // autopilot enabled
+
+	Seed int64      // random seed, the same seed and turns replay the same game
+	rng  *rand.Rand // random generator for snake and food positions
+
+	speed  int
+	starve int
+	eats   int
+}
+
+// SetLevel changes the current level
+func (g *Game) SetLevel(n int) {
+	g.NLevel = n
+	g.Level = g.Levels[n]
+	g.eaten = 0
+}
+
+// Start starts a new life (or the next level, after SetLevel) placing the snakes and the food.
+// With restart, it also resets the scores and lives.
+func (g *Game) Start(restart bool) {
+	if g.rng == nil {
+		g.rng = rand.New(rand.NewSource(g.Seed))
+	}
+
+	g.Food = FoodItem{Point: Point{-1, -1}}
+	g.slow = 0
+
+	for _, p := range g.Players {
+		p.Snake = nil
+		p.Ghost = 0
+		p.Stop()
+	}
+
+	for i, p := range g.Players {
+		if i == 0 && g.Level.hasStart {
+			p.Snake = NewSnake(g.Level.start.X, g.Level.start.Y)
+		} else {
+			p.Snake = NewSnake(g.RandXY())
+		}
+
+		if restart {
+			p.Score = 0
+			p.Lives = 5
+		}
+	}
+
+	g.SpawnFood(Apple)
+
+	g.speed = 1
+	g.starve = 0
+	g.eats = 5
+	g.State = Running
+	g.Crashed = nil
+}
+
+// RandXY returns a random free cell
+func (g *Game) RandXY() (int, int) {
+
+retry:
+	for {
+		x, y := g.rng.Intn(g.Level.W), g.rng.Intn(g.Level.H)
+
+		if g.Food.X == x && g.Food.Y == y {
+			continue retry
+		}
+
+		if g.Level.IsWall(Point{X: x, Y: y}) {
+			continue retry
+		}
+
+		for _, p := range g.Players {
+			if p.Snake != nil && p.Snake.Contains(Point{X: x, Y: y}) {
+				continue retry
+			}
+		}
+
+		return x, y
+	}
+}
+
+// RandFood returns a random position for the food, in the level food spawn area (if any)
+func (g *Game) RandFood() (int, int) {
+	var free []Point
+
+area:
+	for _, p := range g.Level.area {
+		for _, pl := range g.Players {
+			if pl.Snake.Contains(p) {
+				continue area
+			}
+		}
+
+		free = append(free, p)
+	}
+
+	if len(free) == 0 {
+		return g.RandXY()
+	}
+
+	p := free[g.rng.Intn(len(free))]
+	return p.X, p.Y
+}
+
+// GameOver returns true when a player has no lives left
+func (g *Game) GameOver() bool {
+	for _, p := range g.Players {
+		if p.Lives <= 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Winner returns the player with the highest score
+func (g *Game) Winner() string {
+	best, tie := 0, false
+
+	for i, p := range g.Players {
+		switch {
+		case p.Score > g.Players[best].Score:
+			best, tie = i, false
+
+		case i != best && p.Score == g.Players[best].Score:
+			tie = true
+		}
+	}
+
+	if tie {
+		return "DRAW"
+	}
+
+	return fmt.Sprintf("PLAYER %v WINS", best+1)
+}
+
+// Step moves all the snakes one cell and returns the result for each player.
+// Collisions are resolved as if the snakes move at the same time: a head moving into any snake
+// (including the cell the other head is leaving) crashes, and two heads moving into the same cell both crash.
+// A snake with the ghost power-up passes through its own body.
+func (g *Game) Step() []MoveResult {
+	results := make([]MoveResult, len(g.Players))
+	heads := make([]Point, len(g.Players))
+	moving := make([]bool, len(g.Players))
+
+	for i, p := range g.Players {
+		p.Snake.Moved = false
+
+		if p.Dir == Nodir {
+			continue
+		}
+
+		heads[i], results[i] = p.Snake.Next(p.Dir, g.Level)
+		moving[i] = results[i] != Wall
+	}
+
+	for i := range g.Players {
+		if !moving[i] {
+			continue
+		}
+
+		for j, other := range g.Players {
+			if j == i && g.Players[i].Ghost > 0 {
+				continue
+			}
+
+			if other.Snake.Contains(heads[i]) || (j != i && moving[j] && heads[j] == heads[i]) {
+				results[i] = Body
+				break
+			}
+		}
+	}
+
+	for i, p := range g.Players {
+		if !moving[i] || results[i] == Body {
+			continue
+		}
+
+		eat := heads[i] == g.Food.Point
+		p.Snake.Advance(heads[i], eat)
+
+		if eat {
+			results[i] = Food
+		}
+	}
+
+	return results
+}
+
+// Tick runs one step of the game logic: it applies the next queued turn for each player,
+// moves the snakes and updates scores, lives and level state.
+// It doesn't depend on the user input, so a game can be played with scripted turns.
+// It returns true if the score or the lives changed.
+// Nothing moves after a crash, at game over or when the level is complete, until the next Start.
+func (g *Game) Tick() bool {
+	if g.State != Running {
+		return false
+	}
+
+	for _, p := range g.Players {
+		p.NextTurn()
+	}
+
+	if g.Autopilot && g.Bot != nil {
+		g.Players[0].Dir = g.Bot(g, g.Players[0])
+	}
+
+	var crashed []int
+	ate := false
+
+	for i, mres := range g.Step() {
+		p := g.Players[i]
+
+		switch mres {
+		case Wall, Body:
+			p.Lives--
+			crashed = append(crashed, i)
+
+		case Food:
+			g.Eat(p)
+			ate = true
+		}
+	}
+
+	if len(crashed) == 0 && g.Expire() {
+		ate = true // speed changed, update the title
+	}
+
+	switch {
+	case len(crashed) > 0:
+		g.Crashed = crashed
+		g.State = Crashed
+
+		if g.GameOver() {
+			g.State = Over
+		}
+
+		for _, p := range g.Players {
+			p.Stop()
+		}
+
+	case g.Level.goal > 0 && g.eaten >= g.Level.goal:
+		for _, p := range g.Players {
+			p.Stop()
+		}
+
+		g.State = LevelComplete
+	}
+
+	return ate || len(crashed) > 0
+}
@@ -0,0 +1,167 @@
+package game
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// newGame returns a headless single player game on an empty 10x10 board, with a fixed seed.
+// The snake cells go from the tail to the head, and the food is out of the way.
+func newGame(dir Dir, cells ...Point) (*Game, *Player) {
+	p := &Player{}
+	g := &Game{Levels: []*Level{NewLevel(10, 10, false)}, Players: []*Player{p}, Seed: 1}
+	g.SetLevel(0)
+	g.Start(true)
+
+	p.Snake = &Snake{Cells: cells}
+	p.Dir = dir
+	g.Food = FoodItem{Point: Point{9, 9}, Kind: Apple}
+
+	return g, p
+}
+
+// heads ticks the game n times and returns the head positions
+func heads(g *Game, p *Player, n int) []Point {
+	var list []Point
+
+	for i := 0; i < n; i++ {
+		g.Tick()
+		list = append(list, p.Snake.Head())
+	}
+
+	return list
+}
+
+func TestQuickTurns(t *testing.T) {
+	g, p := newGame(Right, Point{2, 5}, Point{3, 5}, Point{4, 5})
+
+	// a U-turn with two key presses in the same tick: the second turn waits for the next tick
+	p.Turn(Up)
+	p.Turn(Left)
+
+	if got, want := heads(g, p, 3), []Point{{4, 6}, {3, 6}, {2, 6}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got heads %v, want %v", got, want)
+	}
+
+	if p.Lives != 5 || g.State != Running {
+		t.Errorf("crashed: %v lives, state %v", p.Lives, g.State)
+	}
+}
+
+func TestReverse(t *testing.T) {
+	g, p := newGame(Right, Point{2, 5}, Point{3, 5}, Point{4, 5})
+
+	p.Turn(Left) // into the neck
+
+	if len(p.turns) != 0 {
+		t.Errorf("reversal queued: %v", p.turns)
+	}
+
+	p.Turn(Up)
+	p.Turn(Down) // reverses the queued turn
+
+	if want := []Dir{Up}; !reflect.DeepEqual(p.turns, want) {
+		t.Errorf("got turns %v, want %v", p.turns, want)
+	}
+
+	if got, want := heads(g, p, 2), []Point{{4, 6}, {4, 7}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got heads %v, want %v", got, want)
+	}
+}
+
+func TestMaxTurns(t *testing.T) {
+	g, p := newGame(Right, Point{2, 5}, Point{3, 5}, Point{4, 5})
+
+	p.Turn(Up)
+	p.Turn(Left)
+	p.Turn(Down)
+	p.Turn(Right) // the queue is full
+
+	if want := []Dir{Up, Left, Down}; len(p.turns) != MaxTurns || !reflect.DeepEqual(p.turns, want) {
+		t.Errorf("got turns %v, want %v", p.turns, want)
+	}
+
+	if got, want := heads(g, p, 4), []Point{{4, 6}, {3, 6}, {3, 5}, {3, 4}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got heads %v, want %v", got, want)
+	}
+}
+
+func TestReverseLength1(t *testing.T) {
+	g, p := newGame(Right, Point{5, 5})
+
+	p.Turn(Left) // no neck to run into
+
+	if got, want := heads(g, p, 2), []Point{{4, 5}, {3, 5}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got heads %v, want %v", got, want)
+	}
+}
+
+// TestGameOver checks that nothing moves after the game is over, even with the autopilot
+func TestGameOver(t *testing.T) {
+	g, p := newGame(Right, Point{8, 5}, Point{9, 5})
+	p.Lives = 1
+
+	g.Tick() // into the wall
+
+	if !g.GameOver() || g.State != Over {
+		t.Fatalf("game over %v, state %v", g.GameOver(), g.State)
+	}
+
+	g.Bot = AStarBot
+	g.Autopilot = true
+
+	snake := append([]Point(nil), p.Snake.Cells...)
+
+	for i := 0; i < 10; i++ {
+		if g.Tick() {
+			t.Fatal("score or lives changed")
+		}
+	}
+
+	if p.Lives != 0 || p.Score != 0 || !reflect.DeepEqual(p.Snake.Cells, snake) {
+		t.Errorf("the game went on: lives %v, score %v, snake %v", p.Lives, p.Score, p.Snake.Cells)
+	}
+}
+
+// TestBench checks that the same seed plays the same games
+func TestBench(t *testing.T) {
+	levels := []*Level{NewLevel(12, 10, false)}
+
+	var a, b bytes.Buffer
+
+	if err := Bench(&a, 3, []string{"astar", "hamilton"}, levels, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Bench(&b, 3, []string{"astar", "hamilton"}, levels, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if a.String() != b.String() {
+		t.Errorf("different results:\n%v\n%v", a.String(), b.String())
+	}
+
+	if err := Bench(&a, 1, []string{"nobot"}, levels, 1); err == nil {
+		t.Error("unknown bot accepted")
+	}
+}
+
+func TestSpeed(t *testing.T) {
+	g, _ := newGame(Right, Point{5, 5})
+
+	for _, tt := range []struct{ speed, slow, want int }{
+		{1, 0, 1},
+		{2, 0, 2},
+		{2, 10, 1},
+		{3, 10, 1},
+		{5, 10, 2},
+		{MaxSpeed, 10, MaxSpeed - slowDown},
+	} {
+		g.speed, g.slow = tt.speed, tt.slow
+
+		if got := g.Speed(); got != tt.want {
+			t.Errorf("speed %v, slow %v: got %v, want %v", tt.speed, tt.slow, got, tt.want)
+		}
+	}
+}
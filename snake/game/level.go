@@ -0,0 +1,203 @@
+package game
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+ * A level file is a plain-text grid, where each character is a cell:
+ *
+ *   '#'      wall
+ *   'S'      snake start position
+ *   '*'      food spawn area (if there are none, food can appear anywhere)
+ *   '.', ' ' empty cell
+ *
+ * Lines starting with ';' are comments and lines in the form "name: value" are options:
+ *
+ *   goal: N  number of food items to eat to complete the level
+ *   wrap: true|false  the snake comes out on the opposite edge
+ *
+ * The first row of the grid is the top of the board.
+ */
+
+// A Level describes the board: size, walls, start position and food spawn area
+type Level struct {
+	name string
+
+	W, H int
+	Wrap bool
+
+	Walls map[Point]bool
+	area  []Point // food spawn area, empty for anywhere
+
+	start    Point
+	hasStart bool
+
+	goal int // food to eat to complete the level, 0 for no limit
+}
+
+// NewLevel returns an empty level
+func NewLevel(w, h int, wrap bool) *Level {
+	return &Level{W: w, H: h, Wrap: wrap, Walls: map[Point]bool{}}
+}
+
+// IsWall returns true if there is a wall at p
+func (l *Level) IsWall(p Point) bool {
+	return l.Walls[p]
+}
+
+// Step returns the cell next to p in direction d, wrapping around the edges if needed.
+// It returns false if the cell is a wall or outside the board.
+func (l *Level) Step(p Point, d Dir) (Point, bool) {
+	switch d {
+	case Up:
+		p.Y++
+
+	case Down:
+		p.Y--
+
+	case Left:
+		p.X--
+
+	case Right:
+		p.X++
+	}
+
+	if p.X < 0 || p.Y < 0 || p.X >= l.W || p.Y >= l.H {
+		if !l.Wrap {
+			return p, false
+		}
+
+		p.X = (p.X + l.W) % l.W
+		p.Y = (p.Y + l.H) % l.H
+	}
+
+	return p, !l.IsWall(p)
+}
+
+// ReadLevel reads a level file
+func ReadLevel(path string, wrap bool) (*Level, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	l := NewLevel(0, 0, wrap)
+	l.name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	var rows []string
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if name, value, ok := strings.Cut(line, ":"); ok {
+			value = strings.TrimSpace(value)
+
+			switch strings.TrimSpace(name) {
+			case "goal":
+				if l.goal, err = strconv.Atoi(value); err != nil {
+					return nil, fmt.Errorf("%v: invalid goal %q", path, value)
+				}
+
+			case "wrap":
+				if l.Wrap, err = strconv.ParseBool(value); err != nil {
+					return nil, fmt.Errorf("%v: invalid wrap %q", path, value)
+				}
+
+			default:
+				return nil, fmt.Errorf("%v: unknown option %q", path, name)
+			}
+
+			continue
+		}
+
+		rows = append(rows, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// ignore empty lines at the end
+	for len(rows) > 0 && strings.TrimSpace(rows[len(rows)-1]) == "" {
+		rows = rows[:len(rows)-1]
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%v: empty level", path)
+	}
+
+	l.H = len(rows)
+	for _, r := range rows {
+		if len(r) > l.W {
+			l.W = len(r)
+		}
+	}
+
+	for i, r := range rows {
+		y := l.H - 1 - i // first row is the top
+
+		for x, c := range r {
+			p := Point{X: x, Y: y}
+
+			switch c {
+			case '#':
+				l.Walls[p] = true
+
+			case 'S':
+				l.start = p
+				l.hasStart = true
+
+			case '*':
+				l.area = append(l.area, p)
+
+			case '.', ' ':
+
+			default:
+				return nil, fmt.Errorf("%v: invalid character %q at line %d", path, c, i+1)
+			}
+		}
+	}
+
+	return l, nil
+}
+
+// ReadLevels reads all the level files (*.txt) in a directory, sorted by name
+func ReadLevels(dir string, wrap bool) ([]*Level, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no levels in %v", dir)
+	}
+
+	sort.Strings(files)
+
+	var levels []*Level
+
+	for _, f := range files {
+		l, err := ReadLevel(f, wrap)
+		if err != nil {
+			return nil, err
+		}
+
+		levels = append(levels, l)
+	}
+
+	return levels, nil
+}
@@ -0,0 +1,165 @@
+package game
+
+const MaxTurns = 3 // max queued direction changes
+
+type Dir int
+
+const (
+	Nodir Dir = iota
+	Up
+	Down
+	Left
+	Right
+)
+
+// Opposite returns the opposite direction
+func (d Dir) Opposite() Dir {
+	switch d {
+	case Up:
+		return Down
+	case Down:
+		return Up
+	case Left:
+		return Right
+	case Right:
+		return Left
+	}
+
+	return Nodir
+}
+
+type MoveResult int
+
+const (
+	Space MoveResult = iota
+	Wall
+	Body
+	Food
+)
+
+type Point struct {
+	X, Y int
+}
+
+type Snake struct {
+	Cells []Point
+
+	Tail  Point // cell left by the tail in the last move (for the animation)
+	Moved bool  // the snake moved in the last tick
+}
+
+func NewSnake(x, y int) *Snake {
+	return &Snake{Cells: []Point{{X: x, Y: y}}}
+}
+
+// Contains returns true if p is part of the snake
+func (s *Snake) Contains(p Point) bool {
+	for _, c := range s.Cells {
+		if c == p {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Snake) Head() Point {
+	l := len(s.Cells)
+	return s.Cells[l-1]
+}
+
+// Move moves the snake in direction d, checking for walls, its own body and food
+func (s *Snake) Move(d Dir, l *Level, food Point) MoveResult {
+	p, res := s.Next(d, l)
+	if res == Wall {
+		return Wall
+	}
+
+	if s.Contains(p) {
+		return Body
+	}
+
+	grow := p == food
+	s.Advance(p, grow)
+
+	if grow {
+		return Food
+	}
+
+	return Space
+}
+
+// Next returns the next position of the head moving in direction d (Wall if it hits a wall)
+func (s *Snake) Next(d Dir, l *Level) (Point, MoveResult) {
+	p, ok := l.Step(s.Head(), d)
+	if !ok {
+		return p, Wall
+	}
+
+	return p, Space
+}
+
+// Advance moves the head to p, growing the snake by one cell if grow is true
+func (s *Snake) Advance(p Point, grow bool) {
+	s.Tail = s.Cells[0]
+	s.Cells = append(s.Cells, p)
+
+	if !grow {
+		s.Cells = s.Cells[1:]
+	}
+
+	s.Moved = true
+}
+
+// Shrink removes up to n cells from the tail, keeping at least the head
+func (s *Snake) Shrink(n int) {
+	if n >= len(s.Cells) {
+		n = len(s.Cells) - 1
+	}
+
+	s.Cells = s.Cells[n:]
+}
+
+// A Player is a snake with its direction, score and lives
+type Player struct {
+	Snake *Snake
+	Dir   Dir
+	turns []Dir // queued direction changes, one is applied at each tick
+
+	Score int
+	Lives int
+	Ghost int // ticks left with ghost power-up (can pass through its own body)
+}
+
+// Turn queues a direction change. Turns that don't change direction, or that reverse it
+// (the snake would run into its own neck) are ignored, as well as turns when the queue is full.
+func (p *Player) Turn(d Dir) {
+	last := p.Dir
+	if n := len(p.turns); n > 0 {
+		last = p.turns[n-1]
+	}
+
+	if d == last || len(p.turns) >= MaxTurns {
+		return
+	}
+
+	if d == last.Opposite() && len(p.Snake.Cells) > 1 {
+		return
+	}
+
+	p.turns = append(p.turns, d)
+}
+
+// NextTurn applies the next queued direction change
+func (p *Player) NextTurn() {
+	if len(p.turns) > 0 {
+		p.Dir = p.turns[0]
+		p.turns = p.turns[1:]
+	}
+}
+
+// Stop stops the snake and clears the queued turns
+func (p *Player) Stop() {
+	p.Dir = Nodir
+	p.turns = nil
+}
@@ -1,14 +1,18 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"image/color"
-	"math/rand"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
+
+	"github.com/raff/ebi-games/snake/game"
 )
 
 const (
@@ -17,23 +21,66 @@ const (
 	border = 6
 
 	title = "Snake"
+
+	minCols = 8 // minimum board size when resizing the window
+	minRows = 8
 )
 
 var (
 	bgColor    = color.NRGBA{40, 40, 40, 255}
-	snakeColor = color.NRGBA{0, 255, 0, 255} // green
-	foodColor  = color.NRGBA{255, 0, 0, 255} // green
+	snakeColor = color.NRGBA{0, 255, 0, 255}   // green
+	otherColor = color.NRGBA{0, 160, 255, 255} // light blue (second player)
+	wallColor  = color.NRGBA{128, 128, 128, 255}
+
+	foodColors = []color.NRGBA{
+		game.Apple:  {255, 0, 0, 255},     // red
+		game.Bonus:  {255, 215, 0, 255},   // gold
+		game.Slow:   {0, 200, 200, 255},   // cyan
+		game.Shrink: {255, 128, 0, 255},   // orange
+		game.Ghost:  {200, 200, 255, 255}, // lavender
+	}
 
 	noop = &ebiten.DrawImageOptions{}
 )
 
 func main() {
-	rand.Seed(time.Now().Unix())
+	wrap := flag.Bool("wrap", false, "the snake comes out on the opposite edge")
+	levels := flag.String("levels", "", "directory with the level files")
+	players := flag.Int("players", 1, "number of players (1 or 2)")
+	bot := flag.String("bot", "astar", "autopilot bot: "+game.BotNames())
+	seed := flag.Int64("seed", 0, "random seed (0: current time)")
+	flag.Parse()
+
+	if *seed == 0 {
+		*seed = time.Now().Unix()
+	}
+
+	g := &Game{Game: &game.Game{Seed: *seed}, wrap: *wrap}
 
-	g := &Game{}
+	if *levels != "" {
+		l, err := game.ReadLevels(*levels, *wrap)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		g.Levels = l
+	}
+
+	if b, ok := game.Bots[*bot]; ok {
+		g.Bot = b
+	} else {
+		log.Fatalf("unknown bot %q: should be one of %v", *bot, game.BotNames())
+	}
+
+	g.addPlayer(map[ebiten.Key]game.Dir{ebiten.KeyUp: game.Up, ebiten.KeyDown: game.Down, ebiten.KeyLeft: game.Left, ebiten.KeyRight: game.Right}, snakeColor)
+
+	if *players > 1 {
+		g.addPlayer(map[ebiten.Key]game.Dir{ebiten.KeyW: game.Up, ebiten.KeyS: game.Down, ebiten.KeyA: game.Left, ebiten.KeyD: game.Right}, otherColor)
+	}
 
 	ebiten.SetVsyncEnabled(false)
 	ebiten.SetScreenClearedEveryFrame(false)
+	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
 	ebiten.SetWindowSize(g.Init(ebiten.ScreenSizeInFullscreen()))
 	ebiten.SetWindowTitle(g.Score())
 	if err := ebiten.RunGame(g); err != nil {
@@ -41,132 +88,123 @@ func main() {
 	}
 }
 
-type Dir int
+// A control is the keys and color of a player
+type control struct {
+	keys  map[ebiten.Key]game.Dir
+	color color.NRGBA
+}
 
-const (
-	Nodir Dir = iota
-	Up
-	Down
-	Left
-	Right
-)
+// Game is the game logic, with the window, the drawing and the keyboard controls
+type Game struct {
+	*game.Game
 
-type MoveResult int
+	controls []control // for each player
 
-const (
-	Space MoveResult = iota
-	Wall
-	Body
-	Food
-)
+	wrap  bool // default wrap mode
+	sized bool // the level is an empty board sized to the window (no level files)
 
-type Point struct {
-	x, y int
-}
+	message string
 
-type Snake struct {
-	cells []Point
-}
+	ww, wh int // window width, height
+	tw, th int // game tile width, height
+
+	cols, rows int
+
+	canvas *ebiten.Image // image buffer
+	tile   *ebiten.Image // white tile, colored when drawn
+	redraw bool          // content changed
 
-func NewSnake(x, y int) *Snake {
-	return &Snake{cells: []Point{Point{x: x, y: y}}}
+	progress float64 // fraction of the move to the next tick, for the animation
+
+	frame int
 }
 
-func (s *Snake) Head() Point {
-	l := len(s.cells)
-	return s.cells[l-1]
+// addPlayer adds a player, moving with the keys
+func (g *Game) addPlayer(keys map[ebiten.Key]game.Dir, c color.NRGBA) {
+	g.Players = append(g.Players, &game.Player{})
+	g.controls = append(g.controls, control{keys: keys, color: c})
 }
 
-func (s *Snake) Move(d Dir, w, h int, food Point) MoveResult {
-	p := s.Head()
+// SetLevel changes the current level, resizing the board if needed
+func (g *Game) SetLevel(n int) {
+	g.Game.SetLevel(n)
 
-	switch d {
-	case Up:
-		if p.y >= h-1 {
-			return Wall
-		}
-		p.y++
+	if g.canvas != nil && g.Level.W == g.cols && g.Level.H == g.rows {
+		return
+	}
 
-	case Down:
-		if p.y <= 0 {
-			return Wall
-		}
-		p.y--
+	resize := g.canvas != nil
 
-	case Left:
-		if p.x <= 0 {
-			return Wall
-		}
-		p.x--
+	g.cols, g.rows = g.Level.W, g.Level.H
 
-	case Right:
-		if p.x >= w-1 {
-			return Wall
-		}
-		p.x++
-	}
+	g.ww = (g.tw * g.cols) + (2 * border)
+	g.wh = (g.th * g.rows) + (2 * border)
 
-        for _, b := range s.cells {
-            if p.x == b.x && p.y == b.y {
-                return Body
-            }
-        }
+	g.newCanvas()
 
-	s.cells = append(s.cells, p)
-	if p.x == food.x && p.y == food.y {
-		return Food
+	if resize {
+		ebiten.SetWindowSize(g.ww, g.wh)
 	}
-
-	s.cells = s.cells[1:]
-	return Space
 }
 
-type Game struct {
-	snake *Snake
-	food  Point
-	dir   Dir
+func (g *Game) newCanvas() {
+	if g.canvas != nil {
+		g.canvas.Dispose()
+	}
 
-	message string
+	g.canvas = ebiten.NewImage(g.ww, g.wh)
+	g.canvas.Fill(bgColor)
+}
 
-	ww, wh int // window width, height
-	tw, th int // game tile width, height
+// Resize changes the size of an empty board (when there are no level files) to cols x rows.
+// It returns false if the board can't be resized, because it is too small for the snakes.
+func (g *Game) Resize(cols, rows int) bool {
+	if !g.sized || cols < minCols || rows < minRows {
+		return false
+	}
 
-	cols, rows int
+	inside := func(p game.Point) bool {
+		return p.X < cols && p.Y < rows
+	}
 
-	score int
-	lives int
+	for _, p := range g.Players {
+		for _, c := range p.Snake.Cells {
+			if !inside(c) {
+				return false
+			}
+		}
+	}
 
-	canvas *ebiten.Image // image buffer
-	redraw bool          // content changed
+	g.Level = game.NewLevel(cols, rows, g.Level.Wrap)
+	g.Levels[g.NLevel] = g.Level
 
-	frame    int
-	speed    int
-	maxspeed int
+	g.cols, g.rows = cols, rows
 
-	starve int
-	eats   int
-}
+	g.ww = (g.tw * g.cols) + (2 * border)
+	g.wh = (g.th * g.rows) + (2 * border)
 
-func (g *Game) RandXY() (int, int) {
+	if !inside(g.Food.Point) {
+		g.SpawnFood(g.Food.Kind)
+	}
 
-retry:
-	for {
-		x, y := rand.Intn(g.cols), rand.Intn(g.rows)
+	g.newCanvas()
+	g.redraw = true
+	return true
+}
 
-		if g.food.x == x && g.food.y == y {
-			continue retry
-		}
+// Paused returns true if the snakes are not moving: the game is paused, waiting to start or showing a message
+func (g *Game) Paused() bool {
+	if g.frame <= 0 || g.message != "" {
+		return true
+	}
 
-		if g.snake != nil {
-			for _, p := range g.snake.cells {
-				if p.x == x && p.y == y {
-					continue retry
-				}
-			}
+	for _, p := range g.Players {
+		if p.Dir != game.Nodir {
+			return false
 		}
-
-		return x, y
 	}
+
+	return true
 }
 
 /*
@@ -174,43 +212,34 @@ retry:
  *
  * game.Init(0, 0) : restart, reset all values
  *
- * game.Init(-1, -1) : new life
+ * game.Init(-1, -1) : new life (or next level)
  *
  */
 func (g *Game) Init(w, h int) (int, int) {
 	if w > 0 && h > 0 {
-		g.ww, g.wh = w/2, h/2
-
 		g.tw = cw // g.ww / hcount
 		g.th = cw // g.wh / vcount
 
-		g.cols = g.ww / g.tw
-		g.rows = g.wh / g.th
+		if len(g.Levels) == 0 { // no level files, use an empty board
+			cols := (w / 2) / g.tw
+			rows := (h / 2) / g.th
 
-		g.ww = (g.tw * g.cols) + (2 * border)
-		g.wh = (g.th * g.rows) + (2 * border)
+			g.Levels = []*game.Level{game.NewLevel(cols, rows, g.wrap)}
+			g.sized = true
+		}
+	}
 
-		g.canvas = ebiten.NewImage(g.ww, g.wh)
-		g.canvas.Fill(bgColor)
+	if w >= 0 {
+		g.SetLevel(0)
 	}
 
-	g.snake = NewSnake(g.RandXY())
-	g.food.x, g.food.y = g.RandXY()
+	g.Start(w >= 0)
 
-	g.dir = Nodir
 	g.redraw = true
-	g.speed = 1
-	g.frame = g.speed
-	g.maxspeed = 10
-	g.starve = 0
-	g.eats = 5
+	g.progress = 0
+	g.frame = g.Speed()
 	g.message = ""
 
-	if w >= 0 {
-		g.score = 0
-		g.lives = 5
-	}
-
 	return g.ww, g.wh
 }
 
@@ -218,21 +247,95 @@ func (g *Game) End() {
 }
 
 func (g *Game) Score() string {
-	return fmt.Sprintf("%v - score: %v speed: %v lives: %v", title, g.score, g.speed, g.lives)
-}
+	var sb strings.Builder
+
+	sb.WriteString(title)
+
+	if len(g.Levels) > 1 {
+		fmt.Fprintf(&sb, " - level: %v", g.NLevel+1)
+	}
+
+	if len(g.Players) == 1 {
+		p := g.Players[0]
+		fmt.Fprintf(&sb, " - score: %v speed: %v lives: %v", p.Score, g.Speed(), p.Lives)
+	} else {
+		for i, p := range g.Players {
+			fmt.Fprintf(&sb, " - P%v score: %v lives: %v", i+1, p.Score, p.Lives)
+		}
 
-func (g *Game) Fix(y int) int {
-	return g.rows - 1 - y
+		fmt.Fprintf(&sb, " - speed: %v", g.Speed())
+	}
+
+	return sb.String()
 }
 
-func (g *Game) ScreenCoords(x, y int) (int, int) {
-	return border + (x * g.tw), border + (g.Fix(y) * g.th)
+// screenPos returns the screen position for a (possibly fractional) board position
+func (g *Game) screenPos(x, y float64) (float64, float64) {
+	return float64(border) + x*float64(g.tw), float64(border) + (float64(g.rows-1)-y)*float64(g.th)
 }
 
+// Layout resizes the board to fit the window, if the game is paused.
+// Otherwise the current board is scaled to the window size.
 func (g *Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
+	cols := (outsideWidth - (2 * border)) / g.tw
+	rows := (outsideHeight - (2 * border)) / g.th
+
+	if (cols != g.cols || rows != g.rows) && g.Paused() && g.Resize(cols, rows) {
+		ebiten.SetWindowTitle(g.Score())
+	}
+
 	return g.ww, g.wh
 }
 
+// lerp returns the position at fraction t of the way from cell a to cell b,
+// or b if the cells are not adjacent (the snake wrapped around the edges or shrank)
+func lerp(a, b game.Point, t float64) (float64, float64) {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	if dx*dx+dy*dy != 1 {
+		return float64(b.X), float64(b.Y)
+	}
+
+	return float64(a.X) + t*float64(dx), float64(a.Y) + t*float64(dy)
+}
+
+// drawTile draws a tile of color c at the board position x,y
+func (g *Game) drawTile(x, y float64, c color.NRGBA) {
+	sx, sy := g.screenPos(x, y)
+
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(sx, sy)
+	op.ColorScale.ScaleWithColor(c)
+	g.canvas.DrawImage(g.tile, op)
+}
+
+// drawSnake draws the snake, moving the head and the tail between cells according to g.progress
+func (g *Game) drawSnake(s *game.Snake, c color.NRGBA) {
+	n := len(s.Cells)
+
+	if !s.Moved {
+		for _, p := range s.Cells {
+			g.drawTile(float64(p.X), float64(p.Y), c)
+		}
+
+		return
+	}
+
+	for _, p := range s.Cells[:n-1] {
+		g.drawTile(float64(p.X), float64(p.Y), c)
+	}
+
+	prev := s.Tail // the cell the head is coming from
+	if n > 1 {
+		prev = s.Cells[n-2]
+
+		x, y := lerp(s.Tail, s.Cells[0], g.progress) // the tail leaving its cell
+		g.drawTile(x, y, c)
+	}
+
+	x, y := lerp(prev, s.Cells[n-1], g.progress)
+	g.drawTile(x, y, c)
+}
+
 func (g *Game) Draw(screen *ebiten.Image) {
 	if !g.redraw {
 		return
@@ -244,32 +347,82 @@ func (g *Game) Draw(screen *ebiten.Image) {
 		return
 	}
 
-	tile := ebiten.NewImage(g.tw, g.th)
+	if g.tile == nil {
+		g.tile = ebiten.NewImage(g.tw, g.th)
+		g.tile.Fill(color.White)
+	}
 
 	g.canvas.Fill(bgColor)
 
-	tile.Fill(snakeColor)
-
-	for _, p := range g.snake.cells {
-		sx, sy := g.ScreenCoords(p.x, p.y)
-
-		op := &ebiten.DrawImageOptions{}
-		op.GeoM.Translate(float64(sx), float64(sy))
-		g.canvas.DrawImage(tile, op)
+	for p := range g.Level.Walls {
+		g.drawTile(float64(p.X), float64(p.Y), wallColor)
 	}
 
-	tile.Fill(foodColor)
+	for i, pl := range g.Players {
+		c := g.controls[i].color
+		if pl.Ghost > 0 { // faded while passing through its own body
+			c.A = 128
+		}
 
-	sx, sy := g.ScreenCoords(g.food.x, g.food.y)
+		g.drawSnake(pl.Snake, c)
+	}
 
-	op := &ebiten.DrawImageOptions{}
-	op.GeoM.Translate(float64(sx), float64(sy))
-	g.canvas.DrawImage(tile, op)
+	g.drawTile(float64(g.Food.X), float64(g.Food.Y), foodColors[g.Food.Kind])
 
 	screen.DrawImage(g.canvas, noop)
 	g.redraw = false
 }
 
+// setMessage shows the message for the game state, pausing the game if it isn't running
+func (g *Game) setMessage() {
+	switch g.State {
+	case game.Running:
+		return
+
+	case game.Crashed:
+		g.message = " *** CRASH - Hit <space> to continue ***"
+
+		if len(g.Players) > 1 {
+			var crashed []string
+			for _, i := range g.Crashed {
+				crashed = append(crashed, fmt.Sprintf("PLAYER %v", i+1))
+			}
+
+			g.message = " *** CRASH: " + strings.Join(crashed, ", ") + " - Hit <space> to continue ***"
+		}
+
+	case game.Over:
+		g.message = " *** GAME OVER ***"
+
+		if len(g.Players) > 1 {
+			g.message = " *** GAME OVER - " + g.Winner() + " ***"
+		}
+
+	case game.LevelComplete:
+		g.message = " *** LEVEL COMPLETE - Hit <space> to continue ***"
+
+		if g.NLevel == len(g.Levels)-1 {
+			g.message = " *** ALL LEVELS COMPLETE - Hit <space> to start again ***"
+		}
+	}
+
+	g.frame = 0
+}
+
+// setProgress updates the fraction of the move done, from the frames left to the next tick
+func (g *Game) setProgress() {
+	speed := g.Speed()
+
+	g.progress = float64(g.frame-speed) / float64(game.MaxSpeed-speed+1)
+	if g.progress < 0 {
+		g.progress = 0
+	}
+
+	if !g.Paused() {
+		g.redraw = true
+	}
+}
+
 func (g *Game) Update() error {
 	switch {
 	case inpututil.IsKeyJustPressed(ebiten.KeyR): // (R)estart
@@ -281,83 +434,53 @@ func (g *Game) Update() error {
 		if g.frame > 0 {
 			g.frame = 0
 		} else if g.message != "" {
-			if g.lives > 0 { // new life
+			if g.State == game.LevelComplete { // next level
+				g.SetLevel((g.NLevel + 1) % len(g.Levels))
 				g.Init(-1, -1)
-		                ebiten.SetWindowTitle(g.Score())
+				ebiten.SetWindowTitle(g.Score())
+			} else if !g.GameOver() { // new life
+				g.Init(-1, -1)
+				ebiten.SetWindowTitle(g.Score())
 			}
 			// else game over: restart
 		} else {
-			g.frame = g.speed
+			g.frame = g.Speed()
 		}
 
 	case inpututil.IsKeyJustPressed(ebiten.KeyQ), inpututil.IsKeyJustPressed(ebiten.KeyX): // (Q)uit or e(X)it
 		return ebiten.Termination
 
-	case inpututil.IsKeyJustPressed(ebiten.KeyLeft):
-		g.dir = Left
-
-	case inpututil.IsKeyJustPressed(ebiten.KeyRight):
-		g.dir = Right
-
-	case inpututil.IsKeyJustPressed(ebiten.KeyDown):
-		g.dir = Down
+	case len(g.Players) == 1 && inpututil.IsKeyJustPressed(ebiten.KeyA): // (A)utopilot
+		g.Autopilot = !g.Autopilot
+	}
 
-	case inpututil.IsKeyJustPressed(ebiten.KeyUp):
-		g.dir = Up
+	if g.message == "" {
+		for i, p := range g.Players {
+			for k, d := range g.controls[i].keys {
+				if inpututil.IsKeyJustPressed(k) {
+					p.Turn(d)
+				}
+			}
+		}
 	}
 
-	if g.frame < g.maxspeed {
+	if g.frame < game.MaxSpeed {
 		if g.frame > 0 { // g.frame <= 0 pauses the game
 			g.frame++
+			g.setProgress()
 		}
 
 		return nil
 	}
 
-	g.frame = g.speed
-
-	var mres MoveResult
-
-	switch g.dir {
-	case Up:
-		mres = g.snake.Move(Up, g.cols, g.rows, g.food)
-
-	case Down:
-		mres = g.snake.Move(Down, g.cols, g.rows, g.food)
-
-	case Left:
-		mres = g.snake.Move(Left, g.cols, g.rows, g.food)
-
-	case Right:
-		mres = g.snake.Move(Right, g.cols, g.rows, g.food)
-	}
-
-	switch mres {
-	case Wall, Body:
-		g.lives--
-		if g.lives > 0 {
-			g.frame = 0
-			g.message = " *** CRASH - Hit <space> to continue ***"
-		} else {
-			g.message = " *** GAME OVER ***"
-		}
-
-		g.dir = Nodir
-		ebiten.SetWindowTitle(g.Score())
-
-	case Food:
-		g.score++
-		g.starve++
-		if g.starve >= g.eats && g.speed < g.maxspeed {
-			g.speed++
-			g.starve = 0
-			g.eats += 2
-		}
+	g.frame = g.Speed()
+	g.progress = 0
 
-		g.food.x, g.food.y = g.RandXY()
+	if g.Tick() {
 		ebiten.SetWindowTitle(g.Score())
 	}
 
+	g.setMessage()
 	g.redraw = true
 	return nil
 }
@@ -6,6 +6,8 @@ import (
 	"flag"
 	"image/color"
 	"log"
+	"math/rand"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/audio"
@@ -195,6 +197,7 @@ var (
 	n35 []byte
 
 	audioContext = audio.NewContext(sampleRate)
+	wavs         map[Note][]byte // the embedded samples
 	notes        map[Note]*audio.Player
 	knotes       map[ebiten.Key]Note
 	tnotes       map[int]Note
@@ -227,45 +230,50 @@ func newWavPlayer(b []byte) *audio.Player {
 }
 
 func init() {
-	notes = map[Note]*audio.Player{
-		O3_E:  newWavPlayer(n00), // E
-		O3_F:  newWavPlayer(n01), // F
-		O3_Fx: newWavPlayer(n02), // F#
-		O3_G:  newWavPlayer(n03), // G
-		O3_Gx: newWavPlayer(n04), // G#
-		O3_A:  newWavPlayer(n05), // A
-		O3_Ax: newWavPlayer(n06), // A# / Bb
-		O3_B:  newWavPlayer(n07), // B
-		O4_C:  newWavPlayer(n08), // C4
-		O4_Cx: newWavPlayer(n09), // C#
-		O4_D:  newWavPlayer(n10), // D
-		O4_Dx: newWavPlayer(n11), // D# / Eb
-
-		O4_E:  newWavPlayer(n12), // E
-		O4_F:  newWavPlayer(n13), // F
-		O4_Fx: newWavPlayer(n14), // F#
-		O4_G:  newWavPlayer(n15), // G
-		O4_Gx: newWavPlayer(n16), // G#
-		O4_A:  newWavPlayer(n17), // A
-		O4_Ax: newWavPlayer(n18), // A# / Bb
-		O4_B:  newWavPlayer(n19), // B
-		O5_C:  newWavPlayer(n20), // C5
-		O5_Cx: newWavPlayer(n21), // C#
-		O5_D:  newWavPlayer(n22), // D
-		O5_Dx: newWavPlayer(n23), // D# / Eb
-
-		O5_E:  newWavPlayer(n24), // E
-		O5_F:  newWavPlayer(n25), // F
-		O5_Fx: newWavPlayer(n26), // F#
-		O5_G:  newWavPlayer(n27), // G
-		O5_Gx: newWavPlayer(n28), // G#
-		O5_A:  newWavPlayer(n29), // A
-		O5_Ax: newWavPlayer(n30), // A# / Bb
-		O5_B:  newWavPlayer(n31), // B
-		O6_C:  newWavPlayer(n32), // C6
-		O6_Cx: newWavPlayer(n33), // C#
-		O6_D:  newWavPlayer(n34), // D
-		O6_Dx: newWavPlayer(n35), // D#
+	wavs = map[Note][]byte{
+		O3_E:  n00, // E
+		O3_F:  n01, // F
+		O3_Fx: n02, // F#
+		O3_G:  n03, // G
+		O3_Gx: n04, // G#
+		O3_A:  n05, // A
+		O3_Ax: n06, // A# / Bb
+		O3_B:  n07, // B
+		O4_C:  n08, // C4
+		O4_Cx: n09, // C#
+		O4_D:  n10, // D
+		O4_Dx: n11, // D# / Eb
+
+		O4_E:  n12, // E
+		O4_F:  n13, // F
+		O4_Fx: n14, // F#
+		O4_G:  n15, // G
+		O4_Gx: n16, // G#
+		O4_A:  n17, // A
+		O4_Ax: n18, // A# / Bb
+		O4_B:  n19, // B
+		O5_C:  n20, // C5
+		O5_Cx: n21, // C#
+		O5_D:  n22, // D
+		O5_Dx: n23, // D# / Eb
+
+		O5_E:  n24, // E
+		O5_F:  n25, // F
+		O5_Fx: n26, // F#
+		O5_G:  n27, // G
+		O5_Gx: n28, // G#
+		O5_A:  n29, // A
+		O5_Ax: n30, // A# / Bb
+		O5_B:  n31, // B
+		O6_C:  n32, // C6
+		O6_Cx: n33, // C#
+		O6_D:  n34, // D
+		O6_Dx: n35, // D#
+	}
+
+	notes = map[Note]*audio.Player{}
+	for n, b := range wavs {
+		notes[n] = newWavPlayer(b)
 	}
 
 	knotes = map[ebiten.Key]Note{
@@ -291,7 +299,7 @@ func init() {
 		ebiten.KeyU:            O4_Ax,
 		ebiten.KeyI:            O4_B,
 		ebiten.KeyO:            O5_C,
-		ebiten.KeyP:            O5_Cx,
+		ebiten.KeyBackslash:    O5_Cx, // P is for playback
 		ebiten.KeyBracketLeft:  O5_D,
 		ebiten.KeyBracketRight: O5_Dx,
 
@@ -342,6 +350,8 @@ func init() {
 		P5 | V0: O5_F,
 	}
 
+	initFingerings()
+
 	if t, err := util.ReadTiles(bytes.NewBuffer(trumpetPng), 2, 4); err == nil {
 		tiles = t
 	} else {
@@ -368,6 +378,135 @@ func pplayNote(n Note, play bool) {
 
 type Game struct {
 	redraw bool
+
+	recorder Recorder
+	playback *Playback
+	score    []Event // score loaded with -play
+
+	shown int    // valves and partial shown
+	title string // window title
+
+	quiz      *Quiz // practice mode
+	quizNotes int   // notes in a quiz session
+	stats     Stats // quiz results
+	rng       *rand.Rand
+
+	midiFile string // export the recordings to these files
+	wavFile  string
+}
+
+// playNote plays (or stops) a note from the keyboard or the valves, recording it
+func (g *Game) playNote(n Note, play bool) {
+	g.recorder.Add(n, play)
+	pplayNote(n, play)
+}
+
+// record starts or stops recording. When it stops, the recording is exported (if requested).
+func (g *Game) record() {
+	if !g.recorder.Recording() {
+		g.stopPlayback()
+		g.recorder.Start()
+		return
+	}
+
+	g.recorder.Stop()
+
+	if g.midiFile != "" {
+		if err := writeFile(g.midiFile, g.recorder.Events, WriteMIDI); err != nil {
+			log.Println(err)
+		}
+	}
+
+	if g.wavFile != "" {
+		if err := writeFile(g.wavFile, g.recorder.Events, WriteWAV); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// play starts playing the last recording (or the score, if nothing was recorded), or stops the playback
+func (g *Game) play() {
+	if g.playback != nil {
+		g.stopPlayback()
+		return
+	}
+
+	if g.recorder.Recording() {
+		g.record()
+	}
+
+	events := g.recorder.Events
+	if len(events) == 0 {
+		events = g.score
+	}
+
+	if len(events) > 0 {
+		g.playback = NewPlayback(events)
+	}
+}
+
+func (g *Game) stopPlayback() {
+	if g.playback != nil {
+		g.playback.Stop()
+		g.playback = nil
+	}
+}
+
+// newQuiz starts a new practice session
+func (g *Game) newQuiz() {
+	g.stopPlayback()
+	g.quiz = NewQuiz(g.quizNotes, g.stats, g.rng)
+}
+
+// updateQuiz checks the fingering, saving the results at the end of the session
+func (g *Game) updateQuiz() {
+	done := g.quiz.Done()
+
+	g.quiz.Update(tvalves, time.Now())
+	g.redraw = true // the feedback changes
+
+	if !done && g.quiz.Done() {
+		if err := g.stats.Save(); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// updateDisplay shows the fingering for the note played back (or the valves pressed),
+// and the recorder status and the note played back in the title
+func (g *Game) updateDisplay() {
+	shown := tvalves
+	title := "Trumpetine"
+
+	switch {
+	case g.quiz != nil:
+		title += " quiz - " + g.quiz.Status()
+
+	case g.recorder.Recording():
+		title += " - recording"
+
+	case g.playback != nil:
+		title += " - playing"
+
+		if n, ok := g.playback.Playing(); ok {
+			title += " " + n.String()
+
+			if f, ok := Fingering(n); ok {
+				shown = f
+				title += " (" + FingeringString(f) + ")"
+			}
+		}
+	}
+
+	if shown != g.shown {
+		g.shown = shown
+		g.redraw = true
+	}
+
+	if title != g.title {
+		g.title = title
+		ebiten.SetWindowTitle(title)
+	}
 }
 
 func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
@@ -379,10 +518,16 @@ func (g *Game) Draw(screen *ebiten.Image) {
 		return
 	}
 
-	ima := tiles.Item(tvalves & V123)
+	if g.quiz != nil {
+		drawQuiz(screen, g.quiz, tvalves)
+		g.redraw = false
+		return
+	}
+
+	ima := tiles.Item(g.shown & V123)
 	screen.DrawImage(ima, &ebiten.DrawImageOptions{})
 
-	xs := xscore[tvalves&PMASK]
+	xs := xscore[g.shown&PMASK]
 	if xs != 0 {
 		vector.StrokeLine(screen, xs, yscore, xs+20, yscore, 4, lineColor, false)
 	}
@@ -393,17 +538,35 @@ func (g *Game) Draw(screen *ebiten.Image) {
 func (g *Game) Update() error {
 	for k, v := range knotes {
 		if inpututil.IsKeyJustReleased(k) {
-			pplayNote(v, false)
+			g.playNote(v, false)
 		}
 	}
 
-	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		if g.recorder.Recording() {
+			g.record() // save it
+		}
+
 		return ebiten.Termination
+
+	case inpututil.IsKeyJustPressed(ebiten.KeyTab): // start/stop recording
+		g.record()
+
+	case inpututil.IsKeyJustPressed(ebiten.KeyP): // (P)lay the recording
+		g.play()
+
+	case g.quiz != nil && inpututil.IsKeyJustPressed(ebiten.KeyN): // (N)ew quiz session
+		g.newQuiz()
+	}
+
+	if g.playback != nil && !g.playback.Update() {
+		g.playback = nil
 	}
 
 	for k, v := range knotes {
 		if inpututil.IsKeyJustPressed(k) {
-			pplayNote(v, true)
+			g.playNote(v, true)
 		}
 	}
 
@@ -442,31 +605,61 @@ func (g *Game) Update() error {
 
 	if valves != tvalves {
 		if n, ok := tnotes[tvalves]; ok {
-			pplayNote(n, false)
+			g.playNote(n, false)
 		}
 
 		tvalves = valves
 		log.Println("valves", valves)
 
 		if n, ok := tnotes[tvalves]; ok {
-			pplayNote(n, true)
+			g.playNote(n, true)
 		}
+	}
 
-		g.redraw = true
+	if g.quiz != nil {
+		g.updateQuiz()
 	}
 
+	g.updateDisplay()
 	return nil
 }
 
 func main() {
+	g := &Game{redraw: true}
+
 	flag.BoolVar(&playAudio, "audio", playAudio, "play notes")
+	flag.StringVar(&g.midiFile, "midi", "", "export the recordings to this MIDI file")
+	flag.StringVar(&g.wavFile, "wav", "", "export the recordings to this WAV file")
+	score := flag.String("play", "", "play this score (a MIDI file or a text score), showing the fingering")
+	flag.IntVar(&g.quizNotes, "quiz", 0, "practice mode: play the notes shown on the staff, with this number of notes in a session")
 	flag.Parse()
 
+	if g.quizNotes > 0 {
+		stats, err := LoadStats()
+		if err != nil {
+			log.Println(err)
+		}
+
+		g.stats = stats
+		g.rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+		g.newQuiz()
+	}
+
+	if *score != "" {
+		events, err := LoadScore(*score)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		g.score = events
+		g.playback = NewPlayback(events)
+	}
+
 	ebiten.SetWindowSize(screenWidth*2, screenHeight*2)
 	ebiten.SetWindowTitle("Trumpetine")
 	ebiten.SetVsyncEnabled(false)
 	ebiten.SetScreenClearedEveryFrame(false)
-	if err := ebiten.RunGame(&Game{redraw: true}); err != nil {
+	if err := ebiten.RunGame(g); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	noteNames = []string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+	semitones = map[byte]int{'C': 0, 'D': 2, 'E': 4, 'F': 5, 'G': 7, 'A': 9, 'B': 11}
+
+	fingerings map[Note]int // reverse of tnotes
+)
+
+// initFingerings builds the reverse lookup of tnotes. If a note has more than one fingering,
+// the one with fewer valves pressed is used.
+func initFingerings() {
+	fingerings = map[Note]int{}
+
+	for f, n := range tnotes {
+		if p, ok := fingerings[n]; !ok || valveCount(f) < valveCount(p) {
+			fingerings[n] = f
+		}
+	}
+}
+
+func valveCount(f int) int {
+	c := 0
+	for _, v := range []int{V1, V2, V3} {
+		if f&v != 0 {
+			c++
+		}
+	}
+
+	return c
+}
+
+// Fingering returns the valves and partial (as in tnotes) to play the note.
+// It returns false if the note can't be played with the valves.
+func Fingering(n Note) (int, bool) {
+	f, ok := fingerings[n]
+	return f, ok
+}
+
+// FingeringString returns a description of the valves and partial, e.g. "valves 1 3, partial 2"
+func FingeringString(f int) string {
+	var valves []string
+	for i, v := range []int{V1, V2, V3} {
+		if f&v != 0 {
+			valves = append(valves, strconv.Itoa(i+1))
+		}
+	}
+
+	s := "open"
+	if len(valves) > 0 {
+		s = "valves " + strings.Join(valves, " ")
+	}
+
+	for i, p := range []int{P1, P2, P3, P4, P5} {
+		if f&PMASK == p {
+			s += fmt.Sprintf(", partial %d", i+1)
+		}
+	}
+
+	return s
+}
+
+// MIDI returns the MIDI note number for the note
+func (n Note) MIDI() int {
+	return int(n) + midiE3
+}
+
+// NoteFromMIDI returns the note for a MIDI note number, if the trumpet can play it
+func NoteFromMIDI(m int) (Note, bool) {
+	n := Note(m - midiE3)
+	return n, n >= O3_E && n <= O6_Dx
+}
+
+// String returns the note name, e.g. C#4
+func (n Note) String() string {
+	m := n.MIDI()
+	return noteNames[m%12] + strconv.Itoa(m/12-1)
+}
+
+// ParseNote parses a note name: a letter, an optional # or b and the octave (e.g. C4, F#3, Bb4)
+func ParseNote(s string) (Note, error) {
+	if len(s) < 2 {
+		return 0, fmt.Errorf("invalid note %q", s)
+	}
+
+	semi, ok := semitones[strings.ToUpper(s)[0]]
+	if !ok {
+		return 0, fmt.Errorf("invalid note %q", s)
+	}
+
+	rest := s[1:]
+
+	switch rest[0] {
+	case '#':
+		semi++
+		rest = rest[1:]
+	case 'b':
+		semi--
+		rest = rest[1:]
+	}
+
+	octave, err := strconv.Atoi(rest)
+	if err != nil {
+		return 0, fmt.Errorf("invalid note %q", s)
+	}
+
+	n, ok := NoteFromMIDI((octave+1)*12 + semi)
+	if !ok {
+		return 0, fmt.Errorf("note %q out of range (%v to %v)", s, O3_E, O6_Dx)
+	}
+
+	return n, nil
+}
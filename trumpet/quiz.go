@@ -0,0 +1,338 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/raff/ebi-games/util"
+)
+
+const (
+	settle       = 200 * time.Millisecond // a fingering must be held this long to be an answer
+	feedbackTime = time.Second            // time the answer is shown, before the next note
+
+	// staff layout, matching the staff in the trumpet tiles
+	staffTop   = 130 // the trumpet is above
+	staffLeft  = 20
+	staffRight = 330
+	staffLine  = 149 // first (top) line
+	staffGap   = 9   // distance between lines
+	noteX      = 190
+	textTop    = 226 // the text band is below
+)
+
+var textBand = color.NRGBA{64, 64, 64, 255}
+
+// NoteStats are the quiz results for a note, across sessions
+type NoteStats struct {
+	Asked int           `json:"asked"`
+	Wrong int           `json:"wrong"`
+	Time  time.Duration `json:"time"` // total reaction time, for the right answers
+}
+
+// Weight returns how often the note should be asked: new notes, notes often wrong or slow are asked more
+func (s *NoteStats) Weight() float64 {
+	if s == nil || s.Asked == 0 {
+		return 2
+	}
+
+	w := 1 + 4*float64(s.Wrong)/float64(s.Asked)
+
+	if right := s.Asked - s.Wrong; right > 0 {
+		w += (s.Time / time.Duration(right)).Seconds()
+	}
+
+	return w
+}
+
+// Stats stores the quiz results by note name
+type Stats map[string]*NoteStats
+
+// LoadStats reads the quiz results from the user config directory.
+// A missing file is not an error.
+func LoadStats() (Stats, error) {
+	st := Stats{}
+	err := util.LoadConfig("trumpet", &st)
+	return st, err
+}
+
+// Save writes the quiz results to the user config directory
+func (st Stats) Save() error {
+	return util.SaveConfig("trumpet", st)
+}
+
+// A Quiz is a practice session: it asks Length notes, that the player plays with the valves and partial
+type Quiz struct {
+	Length int
+	Target Note // note to play
+	Count  int  // notes answered
+	Right  int  // right answers
+	Total  time.Duration
+
+	Answer  int  // fingering of the last answer
+	Correct bool // the last answer was right
+
+	stats  Stats
+	missed map[Note]int // notes missed in this session
+	rng    *rand.Rand
+
+	asked    time.Time // when the target was shown
+	held     int       // fingering being held
+	heldAt   time.Time
+	answered time.Time // when the last answer was given (zero while waiting for the answer)
+	ready    bool      // the player released the previous answer
+	done     bool
+}
+
+// NewQuiz starts a session of length notes
+func NewQuiz(length int, stats Stats, rng *rand.Rand) *Quiz {
+	q := &Quiz{Length: length, stats: stats, missed: map[Note]int{}, rng: rng, ready: true}
+	q.next(time.Now())
+	return q
+}
+
+// next picks the next note: a random note with a fingering, weighted by the results
+// (notes missed in this session are asked again soon)
+func (q *Quiz) next(now time.Time) {
+	var candidates []Note
+	var weights []float64
+	var total float64
+
+	for n := O3_E; n <= O6_Dx; n++ {
+		if _, ok := Fingering(n); !ok || (n == q.Target && q.Count > 0) {
+			continue
+		}
+
+		w := q.stats[n.String()].Weight() + 4*float64(q.missed[n])
+
+		candidates = append(candidates, n)
+		weights = append(weights, w)
+		total += w
+	}
+
+	r := q.rng.Float64() * total
+	q.Target = candidates[len(candidates)-1]
+
+	for i, w := range weights {
+		if r < w {
+			q.Target = candidates[i]
+			break
+		}
+
+		r -= w
+	}
+
+	q.asked = now
+	q.answered = time.Time{}
+	q.held = 0
+}
+
+// Update checks the fingering (the valves and partial pressed). An answer is a fingering
+// that plays a note, held for the settle time.
+func (q *Quiz) Update(fingering int, now time.Time) {
+	if q.done {
+		return
+	}
+
+	if !q.answered.IsZero() {
+		if now.Sub(q.answered) < feedbackTime {
+			return
+		}
+
+		if q.Count == q.Length {
+			q.done = true
+			return
+		}
+
+		q.next(now)
+	}
+
+	if _, ok := tnotes[fingering]; !ok { // not playing
+		q.held = 0
+		q.ready = true
+		return
+	}
+
+	if !q.ready { // still holding the last answer
+		return
+	}
+
+	if fingering != q.held {
+		q.held, q.heldAt = fingering, now
+		return
+	}
+
+	if now.Sub(q.heldAt) >= settle {
+		q.answer(fingering, q.heldAt.Sub(q.asked), now)
+	}
+}
+
+// answer records the answer, with the reaction time t
+func (q *Quiz) answer(fingering int, t time.Duration, now time.Time) {
+	q.Count++
+	q.Answer = fingering
+	q.Correct = tnotes[fingering] == q.Target
+	q.answered = now
+	q.ready = false
+
+	name := q.Target.String()
+
+	st := q.stats[name]
+	if st == nil {
+		st = &NoteStats{}
+		q.stats[name] = st
+	}
+
+	st.Asked++
+
+	if q.Correct {
+		q.Right++
+		q.Total += t
+		st.Time += t
+
+		if q.missed[q.Target] > 0 {
+			q.missed[q.Target]--
+		}
+	} else {
+		st.Wrong++
+		q.missed[q.Target]++
+	}
+}
+
+// Done returns true when the session is over
+func (q *Quiz) Done() bool {
+	return q.done
+}
+
+// Accuracy returns the percentage of right answers
+func (q *Quiz) Accuracy() int {
+	if q.Count == 0 {
+		return 0
+	}
+
+	return q.Right * 100 / q.Count
+}
+
+// Average returns the average reaction time, for the right answers
+func (q *Quiz) Average() time.Duration {
+	if q.Right == 0 {
+		return 0
+	}
+
+	return q.Total / time.Duration(q.Right)
+}
+
+// Grade returns the session grade, from A to F: by accuracy, one grade lower if the average time is over 3 seconds
+func (q *Quiz) Grade() string {
+	grades := "ABCDF"
+
+	var g int
+
+	switch a := q.Accuracy(); {
+	case a >= 95:
+		g = 0
+	case a >= 85:
+		g = 1
+	case a >= 70:
+		g = 2
+	case a >= 50:
+		g = 3
+	default:
+		g = 4
+	}
+
+	if q.Average() > 3*time.Second && g < 4 {
+		g++
+	}
+
+	return grades[g : g+1]
+}
+
+// Status returns the session progress, for the title
+func (q *Quiz) Status() string {
+	return fmt.Sprintf("%d/%d, %d%% right, %.1fs", q.Count, q.Length, q.Accuracy(), q.Average().Seconds())
+}
+
+// Feedback returns the text for the bottom of the screen
+func (q *Quiz) Feedback() string {
+	switch {
+	case q.done:
+		return fmt.Sprintf("Grade %v: %d%% right, %.1fs average. N: new session", q.Grade(), q.Accuracy(), q.Average().Seconds())
+
+	case q.answered.IsZero():
+		return fmt.Sprintf("Note %d of %d: play it!", q.Count+1, q.Length)
+
+	case q.Correct:
+		return fmt.Sprintf("Right! %v", q.Target)
+	}
+
+	f, _ := Fingering(q.Target)
+	return fmt.Sprintf("No, %v is %v", q.Target, FingeringString(f))
+}
+
+// drawQuiz draws the trumpet (with the valves pressed), the staff with the target note and the feedback
+func drawQuiz(screen *ebiten.Image, q *Quiz, valves int) {
+	screen.Fill(color.White)
+
+	trumpet := tiles.Item(valves & V123)
+	b := trumpet.Bounds()
+	screen.DrawImage(trumpet.SubImage(image.Rect(b.Min.X, b.Min.Y, b.Max.X, b.Min.Y+staffTop)).(*ebiten.Image), &ebiten.DrawImageOptions{})
+
+	// the clef, from the first tile
+	clef := tiles.Item(0)
+	b = clef.Bounds()
+
+	var op ebiten.DrawImageOptions
+	op.GeoM.Translate(0, staffTop)
+	screen.DrawImage(clef.SubImage(image.Rect(b.Min.X, b.Min.Y+staffTop, b.Min.X+staffLeft+35, b.Min.Y+textTop-10)).(*ebiten.Image), &op)
+
+	for i := 0; i < 5; i++ {
+		y := float32(staffLine + i*staffGap)
+		vector.StrokeLine(screen, staffLeft, y, staffRight, y, 1, lineColor, false)
+	}
+
+	if !q.Done() {
+		drawNote(screen, q.Target)
+	}
+
+	vector.DrawFilledRect(screen, 0, textTop, float32(screen.Bounds().Dx()), float32(screen.Bounds().Dy()-textTop), textBand, false)
+	ebitenutil.DebugPrintAt(screen, q.Feedback(), 8, textTop+4)
+}
+
+// drawNote draws the note on the staff, with the ledger lines and the sharp sign
+func drawNote(screen *ebiten.Image, n Note) {
+	name := n.String()
+
+	// diatonic steps from the bottom line (E4)
+	step := int(name[len(name)-1]-'0')*7 + strings.IndexByte("CDEFGAB", name[0]) - (4*7 + 2)
+
+	y := func(s int) float32 {
+		return float32(staffLine+4*staffGap) - float32(s)*staffGap/2
+	}
+
+	for s := -2; s >= step; s -= 2 { // ledger lines below
+		vector.StrokeLine(screen, noteX-10, y(s), noteX+10, y(s), 1, lineColor, false)
+	}
+
+	for s := 10; s <= step; s += 2 { // ledger lines above
+		vector.StrokeLine(screen, noteX-10, y(s), noteX+10, y(s), 1, lineColor, false)
+	}
+
+	ny := y(step)
+	vector.StrokeCircle(screen, noteX, ny, 5, 2, lineColor, true)
+
+	if name[1] == '#' {
+		x := float32(noteX - 18)
+		vector.StrokeLine(screen, x+2, ny-6, x+2, ny+6, 1, lineColor, false)
+		vector.StrokeLine(screen, x+6, ny-7, x+6, ny+5, 1, lineColor, false)
+		vector.StrokeLine(screen, x, ny-1, x+8, ny-3, 2, lineColor, false)
+		vector.StrokeLine(screen, x, ny+3, x+8, ny+1, 2, lineColor, false)
+	}
+}
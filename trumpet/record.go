@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2/audio/wav"
+)
+
+const (
+	midiE3   = 52     // MIDI note number for O3_E
+	division = 480    // MIDI ticks per quarter note
+	tempo    = 500000 // microseconds per quarter note (120 bpm)
+	program  = 56     // General MIDI trumpet (0 based)
+
+	release = sampleRate / 50 // samples to fade out a note when it stops (20ms)
+)
+
+// An Event is a note starting (On) or stopping, at Time from the start of the recording
+type Event struct {
+	Time time.Duration
+	Note Note
+	On   bool
+}
+
+// A Recorder records the notes played, from the keyboard or the valves
+type Recorder struct {
+	Events []Event
+
+	start     time.Time
+	recording bool
+}
+
+// Start starts a new recording
+func (r *Recorder) Start() {
+	r.Events = nil
+	r.start = time.Now()
+	r.recording = true
+}
+
+// Stop stops recording, stopping the notes still playing
+func (r *Recorder) Stop() {
+	playing := map[Note]bool{}
+
+	for _, e := range r.Events {
+		playing[e.Note] = e.On
+	}
+
+	for n, on := range playing {
+		if on {
+			r.Add(n, false)
+		}
+	}
+
+	r.recording = false
+}
+
+// Recording returns true if the recorder is recording
+func (r *Recorder) Recording() bool {
+	return r.recording
+}
+
+// Add records a note event, if recording
+func (r *Recorder) Add(n Note, on bool) {
+	if r.recording {
+		r.Events = append(r.Events, Event{Time: time.Since(r.start), Note: n, On: on})
+	}
+}
+
+// A Playback plays a list of events
+type Playback struct {
+	events []Event
+	start  time.Time
+	next   int
+
+	last Note // last note started
+	on   map[Note]bool
+}
+
+// NewPlayback returns a playback for the events, starting with the first Update
+func NewPlayback(events []Event) *Playback {
+	return &Playback{events: events, on: map[Note]bool{}}
+}
+
+// Update plays the events that are due. It returns false when all the events have been played.
+func (p *Playback) Update() bool {
+	if p.start.IsZero() {
+		p.start = time.Now()
+	}
+
+	t := time.Since(p.start)
+
+	for ; p.next < len(p.events) && p.events[p.next].Time <= t; p.next++ {
+		e := p.events[p.next]
+		pplayNote(e.Note, e.On)
+
+		p.on[e.Note] = e.On
+		if e.On {
+			p.last = e.Note
+		}
+	}
+
+	return p.next < len(p.events)
+}
+
+// Playing returns the last note started, if it's still playing
+func (p *Playback) Playing() (Note, bool) {
+	return p.last, p.on[p.last]
+}
+
+// Stop stops the playback, and all the notes it started
+func (p *Playback) Stop() {
+	for _, e := range p.events[:p.next] {
+		pplayNote(e.Note, false)
+	}
+
+	p.next = len(p.events)
+}
+
+// WriteMIDI writes the events as a Standard MIDI File (format 0, a single track)
+func WriteMIDI(w io.Writer, events []Event) error {
+	var track bytes.Buffer
+
+	varLen := func(v uint32) {
+		buf := []byte{byte(v & 0x7F)}
+		for v >>= 7; v > 0; v >>= 7 {
+			buf = append([]byte{byte(v&0x7F) | 0x80}, buf...)
+		}
+
+		track.Write(buf)
+	}
+
+	varLen(0)
+	track.Write([]byte{0xFF, 0x51, 0x03, tempo >> 16, (tempo >> 8) & 0xFF, tempo & 0xFF}) // set tempo
+	varLen(0)
+	track.Write([]byte{0xC0, program}) // program change
+
+	var last uint32
+
+	for _, e := range events {
+		tick := uint32(e.Time.Microseconds() * division / tempo)
+		varLen(tick - last)
+		last = tick
+
+		if e.On {
+			track.Write([]byte{0x90, byte(e.Note.MIDI()), 100})
+		} else {
+			track.Write([]byte{0x80, byte(e.Note.MIDI()), 0})
+		}
+	}
+
+	varLen(0)
+	track.Write([]byte{0xFF, 0x2F, 0x00}) // end of track
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("MThd")
+	binary.Write(bw, binary.BigEndian, []uint16{0, 6, 0, 1, division})
+	bw.WriteString("MTrk")
+	binary.Write(bw, binary.BigEndian, uint32(track.Len()))
+	bw.Write(track.Bytes())
+	return bw.Flush()
+}
+
+// samples returns the embedded samples for the note (mono, at sampleRate)
+func samples(n Note) ([]int16, error) {
+	s, err := wav.DecodeWithoutResampling(bytes.NewReader(wavs[n]))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(s)
+	if err != nil {
+		return nil, err
+	}
+
+	// the stream is 16 bit stereo: keep the left channel
+	mono := make([]int16, len(data)/4)
+	for i := range mono {
+		mono[i] = int16(binary.LittleEndian.Uint16(data[i*4:]))
+	}
+
+	return mono, nil
+}
+
+// WriteWAV mixes down the events, with the embedded samples, and writes them as a WAV file (16 bit mono)
+func WriteWAV(w io.Writer, events []Event) error {
+	var mix []int32
+
+	cache := map[Note][]int16{}
+	start := map[Note]int{} // sample where the note started, while playing
+
+	add := func(n Note, from, to int) error {
+		s, ok := cache[n]
+		if !ok {
+			var err error
+			if s, err = samples(n); err != nil {
+				return err
+			}
+
+			cache[n] = s
+		}
+
+		if l := to - from + release; l < len(s) {
+			s = s[:l]
+		}
+
+		for len(mix) < from+len(s) {
+			mix = append(mix, 0)
+		}
+
+		for i, v := range s {
+			if rest := len(s) - i; i >= to-from && rest < release { // fade out
+				v = int16(int(v) * rest / release)
+			}
+
+			mix[from+i] += int32(v)
+		}
+
+		return nil
+	}
+
+	for _, e := range events {
+		pos := int(e.Time * sampleRate / time.Second)
+
+		if e.On {
+			if _, ok := start[e.Note]; ok { // restarted: stop it first
+				if err := add(e.Note, start[e.Note], pos); err != nil {
+					return err
+				}
+			}
+
+			start[e.Note] = pos
+		} else if from, ok := start[e.Note]; ok {
+			if err := add(e.Note, from, pos); err != nil {
+				return err
+			}
+
+			delete(start, e.Note)
+		}
+	}
+
+	for n, from := range start { // never stopped: play the whole sample
+		if err := add(n, from, from+sampleRate*60); err != nil {
+			return err
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	size := uint32(len(mix) * 2)
+
+	bw.WriteString("RIFF")
+	binary.Write(bw, binary.LittleEndian, 36+size)
+	bw.WriteString("WAVEfmt ")
+	binary.Write(bw, binary.LittleEndian, []uint32{16})
+	binary.Write(bw, binary.LittleEndian, []uint16{1, 1}) // PCM, mono
+	binary.Write(bw, binary.LittleEndian, []uint32{sampleRate, sampleRate * 2})
+	binary.Write(bw, binary.LittleEndian, []uint16{2, 16}) // block align, bits per sample
+	bw.WriteString("data")
+	binary.Write(bw, binary.LittleEndian, size)
+
+	for _, v := range mix {
+		if v > 32767 {
+			v = 32767
+		} else if v < -32768 {
+			v = -32768
+		}
+
+		binary.Write(bw, binary.LittleEndian, int16(v))
+	}
+
+	return bw.Flush()
+}
+
+// writeFile writes a file with one of the Write functions
+func writeFile(path string, events []Event, write func(io.Writer, []Event) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := write(f, events); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errMIDI = errors.New("invalid MIDI file")
+
+// LoadScore reads a score: a MIDI file (.mid, .midi) or a text score
+func LoadScore(path string) ([]Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mid", ".midi":
+		return ReadMIDI(f)
+	}
+
+	return ReadScore(f)
+}
+
+// ReadMIDI reads the notes from a Standard MIDI File (all the tracks and channels, except percussions).
+// The notes the trumpet can't play are skipped.
+func ReadMIDI(r io.Reader) ([]Event, error) {
+	type midiEvent struct {
+		tick  uint32
+		note  int
+		on    bool
+		tempo uint32 // tempo change, if not 0
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < 14 || string(data[:4]) != "MThd" {
+		return nil, errMIDI
+	}
+
+	hlen := int(binary.BigEndian.Uint32(data[4:]))
+	ntracks := int(binary.BigEndian.Uint16(data[10:]))
+	div := int16(binary.BigEndian.Uint16(data[12:]))
+
+	if div <= 0 {
+		return nil, fmt.Errorf("MIDI SMPTE time division not supported")
+	}
+
+	var events []midiEvent
+
+	pos := 8 + hlen
+
+	for t := 0; t < ntracks; t++ {
+		if pos+8 > len(data) || string(data[pos:pos+4]) != "MTrk" {
+			return nil, errMIDI
+		}
+
+		end := pos + 8 + int(binary.BigEndian.Uint32(data[pos+4:]))
+		if end > len(data) {
+			return nil, errMIDI
+		}
+
+		track := data[pos+8 : end]
+		pos = end
+
+		i := 0
+		tick := uint32(0)
+		status := byte(0)
+
+		varLen := func() uint32 {
+			v := uint32(0)
+			for i < len(track) {
+				b := track[i]
+				i++
+
+				v = v<<7 | uint32(b&0x7F)
+				if b&0x80 == 0 {
+					break
+				}
+			}
+
+			return v
+		}
+
+		for i < len(track) {
+			tick += varLen()
+			if i >= len(track) {
+				return nil, errMIDI
+			}
+
+			if track[i]&0x80 != 0 {
+				status = track[i]
+				i++
+			} // else running status
+
+			switch {
+			case status == 0xFF: // meta event
+				if i >= len(track) {
+					return nil, errMIDI
+				}
+
+				typ := track[i]
+				i++
+
+				l := int(varLen())
+				if i+l > len(track) {
+					return nil, errMIDI
+				}
+
+				if typ == 0x51 && l == 3 {
+					tempo := uint32(track[i])<<16 | uint32(track[i+1])<<8 | uint32(track[i+2])
+					events = append(events, midiEvent{tick: tick, tempo: tempo})
+				}
+
+				i += l
+				status = 0
+
+			case status == 0xF0, status == 0xF7: // sysex
+				i += int(varLen())
+				status = 0
+
+			case status&0xF0 == 0xC0, status&0xF0 == 0xD0: // one data byte
+				i++
+
+			case status >= 0x80 && status < 0xF0: // two data bytes
+				if i+2 > len(track) {
+					return nil, errMIDI
+				}
+
+				cmd, key, vel := status&0xF0, int(track[i]), track[i+1]
+				i += 2
+
+				if status&0x0F == 9 { // percussions
+					continue
+				}
+
+				switch {
+				case cmd == 0x90 && vel > 0:
+					events = append(events, midiEvent{tick: tick, note: key, on: true})
+				case cmd == 0x80, cmd == 0x90:
+					events = append(events, midiEvent{tick: tick, note: key})
+				}
+
+			default:
+				return nil, errMIDI
+			}
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].tick < events[j].tick })
+
+	var score []Event
+
+	usec := uint32(tempo) // microseconds per quarter, until the first tempo change
+	last := uint32(0)
+	var t time.Duration
+
+	for _, e := range events {
+		t += time.Duration(e.tick-last) * time.Duration(usec) * time.Microsecond / time.Duration(div)
+		last = e.tick
+
+		if e.tempo != 0 {
+			usec = e.tempo
+		} else if n, ok := NoteFromMIDI(e.note); ok {
+			score = append(score, Event{Time: t, Note: n, On: e.on})
+		}
+	}
+
+	return score, nil
+}
+
+// ReadScore reads a text score: a list of notes and rests, separated by spaces or new lines.
+// A note is the note name (see ParseNote) optionally followed by / and the duration,
+// as a fraction of a whole note (4 is a quarter note, the default) with an optional dot
+// (a dotted note is 1.5 times longer). A rest is R with the same durations.
+// The line "tempo N" sets the tempo, in quarter notes per minute (120 by default),
+// and lines starting with # are comments:
+//
+//	# C major scale
+//	tempo 100
+//	C4 D4 E4 F4 G4 A4 B4 C5/2.
+func ReadScore(r io.Reader) ([]Event, error) {
+	var score []Event
+	var t time.Duration
+
+	quarter := time.Duration(tempo) * time.Microsecond
+	scanner := bufio.NewScanner(r)
+
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+
+		switch {
+		case len(fields) == 0, strings.HasPrefix(fields[0], "#"):
+			continue
+
+		case fields[0] == "tempo":
+			bpm := 0
+			if len(fields) == 2 {
+				bpm, _ = strconv.Atoi(fields[1])
+			}
+
+			if bpm <= 0 {
+				return nil, fmt.Errorf("line %d: invalid tempo", line)
+			}
+
+			quarter = time.Minute / time.Duration(bpm)
+			continue
+		}
+
+		for _, f := range fields {
+			name, dur, _ := strings.Cut(f, "/")
+
+			d := quarter
+			if dur != "" {
+				dotted := strings.HasSuffix(dur, ".")
+
+				v, err := strconv.Atoi(strings.TrimSuffix(dur, "."))
+				if err != nil || v <= 0 {
+					return nil, fmt.Errorf("line %d: invalid duration %q", line, f)
+				}
+
+				d = quarter * 4 / time.Duration(v)
+				if dotted {
+					d += d / 2
+				}
+			}
+
+			if strings.EqualFold(name, "R") {
+				t += d
+				continue
+			}
+
+			n, err := ParseNote(name)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+
+			// leave a short gap between notes, so repeated notes can be heard
+			score = append(score, Event{Time: t, Note: n, On: true}, Event{Time: t + d*9/10, Note: n})
+			t += d
+		}
+	}
+
+	return score, scanner.Err()
+}
@@ -0,0 +1,78 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// configPath returns the path of the JSON file for name, in the ebi-games directory
+// of the user config directory
+func configPath(name string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "ebi-games", name+".json"), nil
+}
+
+// LoadConfig reads the JSON file for name (usually the game name) from the user config directory into v.
+// A missing file is not an error, and leaves v unchanged.
+func LoadConfig(name string, v any) error {
+	path, err := configPath(name)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// SaveConfig writes v as JSON to the file for name in the user config directory
+func SaveConfig(name string, v any) error {
+	path, err := configPath(name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+// AddBest adds entry to a best results list sorted by less (equal entries keep their order),
+// keeping up to max entries. It returns the new list and the position of the entry,
+// or -1 if it didn't make it.
+func AddBest[T comparable](list []T, entry T, max int, less func(a, b T) bool) ([]T, int) {
+	list = append(list, entry)
+	sort.SliceStable(list, func(i, j int) bool { return less(list[i], list[j]) })
+
+	if len(list) > max {
+		list = list[:max]
+	}
+
+	for i, e := range list {
+		if e == entry {
+			return list, i
+		}
+	}
+
+	return list, -1
+}
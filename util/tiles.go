@@ -3,7 +3,8 @@ package util
 import (
 	"errors"
 	"image"
-	"image/png"
+	_ "image/jpeg"
+	_ "image/png"
 	"io"
 
 	"github.com/hajimehoshi/ebiten/v2"
@@ -37,28 +38,32 @@ func (t *Tiles) At(x, y int) *ebiten.Image {
 	return t.List[x+y*t.Columns]
 }
 
-// ReadTiles read the tiles from a png file
+// ReadTiles read the tiles from a png (or jpeg) file
 func ReadTiles(r io.Reader, nx, ny int) (*Tiles, error) {
 	return ReadTilesScaledTo(r, nx, ny, 0, 0)
 }
 
+// ReadTilesScaledTo read the tiles from a png (or jpeg) file, scaling each tile to tw x th.
+// When scaling, the image dimension doesn't need to be a multiple of the number of tiles.
 func ReadTilesScaledTo(r io.Reader, nx, ny int, tw, th int) (*Tiles, error) {
-	img, err := png.Decode(r)
+	img, _, err := image.Decode(r)
 	if err != nil {
 		return nil, err
 	}
 
+	scale := tw > 0 && th > 0
+
 	iw, ih := img.Bounds().Dx(), img.Bounds().Dy()
-	if iw%nx != 0 || ih%ny != 0 {
+	if !scale && (iw%nx != 0 || ih%ny != 0) {
 		return nil, errors.New("invalid cells image dimension")
 	}
 
 	ebimg := ebiten.NewImageFromImage(img)
 
-	if tw > 0 && th > 0 {
-		sw, sh := float64(tw)/float64(iw/nx), float64(th)/float64(ih/ny)
+	if scale {
+		sw, sh := float64(tw*nx)/float64(iw), float64(th*ny)/float64(ih)
 
-		scaled := ebiten.NewImage(iw, ih)
+		scaled := ebiten.NewImage(tw*nx, th*ny)
 
 		var op ebiten.DrawImageOptions
 		op.GeoM.Scale(sw, sh)